@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/weather-lambda/internal/fakeprovider"
+)
+
+func main() {
+	addr := flag.String("addr", ":8089", "Listen address")
+	mode := flag.String("mode", fakeprovider.ModeDeterministic, "Mode: deterministic, replay or record")
+	fixtures := flag.String("fixtures", "testdata/provider", "Fixture directory for replay and record modes")
+	upstream := flag.String("upstream", "https://api.openweathermap.org/data/2.5/weather", "Real provider URL for record mode")
+	scriptFile := flag.String("script", "", "JSON file with a list of scripted responses")
+	latency := flag.Duration("latency", 0, "Delay added to every response")
+	flag.Parse()
+
+	opts := []fakeprovider.Option{
+		fakeprovider.WithFixtures(*fixtures),
+		fakeprovider.WithUpstream(*upstream),
+		fakeprovider.WithLatency(*latency),
+	}
+
+	if *scriptFile != "" {
+		data, err := os.ReadFile(*scriptFile)
+		if err != nil {
+			log.Fatalf("Failed to read script: %v", err)
+		}
+		var steps []fakeprovider.Step
+		if err := json.Unmarshal(data, &steps); err != nil {
+			log.Fatalf("Failed to parse script: %v", err)
+		}
+		opts = append(opts, fakeprovider.WithScript(steps...))
+	}
+
+	server, err := fakeprovider.NewServer(*mode, opts...)
+	if err != nil {
+		log.Fatalf("Failed to create fake provider: %v", err)
+	}
+
+	log.Printf("Fake weather provider (%s mode) listening on %s", *mode, *addr)
+	log.Printf("Point WEATHER_API_URL at http://localhost%s/data/2.5/weather", *addr)
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := httpServer.ListenAndServe(); err != nil {
+		log.Fatalf("Fake provider stopped: %v", err)
+	}
+}
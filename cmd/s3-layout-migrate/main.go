@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// Rewrites archived weather data objects into a new S3 key layout and compression
+func main() {
+	to := flag.String("to", string(handlers.KeyLayoutHive), "Target key layout: legacy or hive")
+	compressionName := flag.String("compression", "", "Target compression: none, gzip or zstd (defaults to S3_COMPRESSION)")
+	prefix := flag.String("prefix", "", "Key prefix to migrate (defaults to S3_KEY_PREFIX)")
+	dryRun := flag.Bool("dry-run", false, "Log planned copies without writing")
+	deleteSource := flag.Bool("delete", false, "Delete source objects after a successful copy")
+	limit := flag.Int("limit", 0, "Maximum number of objects to migrate (0 = no limit)")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.AWS.S3Bucket == "" {
+		log.Fatal("S3_BUCKET environment variable is required")
+	}
+
+	layout, err := handlers.ParseKeyLayout(*to)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	archiveCfg := config.LoadArchive()
+	if *prefix == "" {
+		*prefix = archiveCfg.KeyPrefix
+	}
+	if *compressionName == "" {
+		*compressionName = archiveCfg.Compression
+	}
+	compression, err := handlers.ParseCompression(*compressionName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.AWS.Region),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+
+	s3Handler := handlers.NewS3Handler(cfg, sess,
+		handlers.WithKeyLayout(layout),
+		handlers.WithKeyPrefix(*prefix),
+		handlers.WithCompression(compression),
+	)
+
+	// Collect keys first so newly written objects are never revisited
+	var keys []string
+	err = s3Handler.WalkWeatherData(strings.TrimSuffix(*prefix, "/")+"/", func(object storage.Object) bool {
+		key := object.Key
+		if layout == handlers.KeyLayoutHive && strings.Contains(key, "/city=") && handlers.CompressionFromKey(key) == compression {
+			return true
+		}
+		keys = append(keys, key)
+		return *limit <= 0 || len(keys) < *limit
+	})
+	if err != nil {
+		log.Fatalf("Failed to list objects: %v", err)
+	}
+	log.Printf("Found %d objects to migrate to the %s layout", len(keys), layout)
+
+	var migrated, skipped, failed int
+	for _, key := range keys {
+		data, err := s3Handler.GetWeatherData(key)
+		if err != nil {
+			log.Printf("Skipping %s: %v", key, err)
+			failed++
+			continue
+		}
+
+		newKey := s3Handler.ObjectKey(data)
+		if newKey == key {
+			skipped++
+			continue
+		}
+
+		// Objects already in the target encoding are copied server-side; others are re-encoded
+		reencode := handlers.CompressionFromKey(key) != compression
+		if *dryRun {
+			log.Printf("Would migrate %s -> %s (re-encode: %t)", key, newKey, reencode)
+			migrated++
+			continue
+		}
+
+		if reencode {
+			err = s3Handler.StoreWeatherData(data)
+		} else {
+			err = s3Handler.CopyWeatherData(key, newKey)
+		}
+		if err != nil {
+			log.Printf("Error migrating %s: %v", key, err)
+			failed++
+			continue
+		}
+		if *deleteSource {
+			if err := s3Handler.DeleteWeatherData(key); err != nil {
+				log.Printf("Error deleting %s after copy: %v", key, err)
+				failed++
+				continue
+			}
+		}
+		migrated++
+	}
+
+	log.Printf("Migration complete: %d migrated, %d already in place, %d failed (dry run: %t)", migrated, skipped, failed, *dryRun)
+	if failed > 0 {
+		log.Fatalf("%d objects failed to migrate", failed)
+	}
+}
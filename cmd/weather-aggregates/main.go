@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/weather-lambda/internal/aggregates"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+)
+
+// newHandler loads the configuration and creates the aggregates handler over the records table
+func newHandler() (*aggregates.Handler, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	dynamoDB, err := handlers.NewDynamoDBHandler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return aggregates.NewHandler(dynamoDB), nil
+}
+
+func main() {
+	handler, err := newHandler()
+	if err != nil {
+		log.Fatalf("Failed to initialize handler: %v", err)
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
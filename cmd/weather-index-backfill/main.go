@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+)
+
+// Adds the city index attributes to records written before the index existed
+func main() {
+	table := flag.String("table", "", "DynamoDB table to backfill (defaults to DYNAMODB_TABLE)")
+	dryRun := flag.Bool("dry-run", false, "Count the records missing index attributes without updating them")
+	segments := flag.Int("segments", 4, "Number of parallel scan segments")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *table != "" {
+		cfg.AWS.DynamoDBTable = *table
+	}
+	if cfg.AWS.DynamoDBTable == "" {
+		log.Fatal("A table is required: set -table or DYNAMODB_TABLE")
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.AWS.Region),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	dynamoDBHandler, err := handlers.NewDynamoDBHandler(cfg, sess)
+	if err != nil {
+		log.Fatalf("Failed to create DynamoDB handler: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Backfilling %s index attributes in %s (dry run: %t)", handlers.CityTimeIndex, cfg.AWS.DynamoDBTable, *dryRun)
+	result, err := dynamoDBHandler.BackfillIndexAttributes(ctx, *dryRun, *segments)
+	if err != nil {
+		log.Printf("Stopped after %d records updated; rerun to continue", result.Updated)
+		log.Fatalf("Backfill failed: %v", err)
+	}
+
+	verb := "updated"
+	if *dryRun {
+		verb = "need updating"
+	}
+	log.Printf("Backfill complete: %d records scanned, %d %s, %d skipped", result.Scanned, result.Updated, verb, result.Skipped)
+}
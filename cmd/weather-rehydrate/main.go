@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/jobs"
+	"github.com/weather-lambda/internal/services"
+)
+
+// Rebuilds DynamoDB weather records from the raw responses kept in the S3 archive
+func main() {
+	city := flag.String("city", "", "City to rehydrate (defaults to CITY_NAME)")
+	startFlag := flag.String("start", "", "Start of the observation range, RFC3339 (required)")
+	endFlag := flag.String("end", "", "End of the observation range, RFC3339 (defaults to now)")
+	table := flag.String("table", "", "Target DynamoDB table (defaults to DYNAMODB_TABLE)")
+	jobID := flag.String("job", "", "Job ID used for checkpointing (defaults to one derived from the city and range)")
+	batchSize := flag.Int("batch-size", jobs.DefaultRehydrateBatchSize, "Records written between checkpoints")
+	ttlDays := flag.Int("ttl-days", 0, "Expire records this many days from now; 0 keeps them, -1 keeps the TTL derived from collection time")
+	concurrency := flag.Int("concurrency", handlers.DefaultIteratorConcurrency, "Concurrent archive reads")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.AWS.S3Bucket == "" {
+		log.Fatal("S3_BUCKET environment variable is required")
+	}
+	if *city == "" {
+		*city = cfg.Weather.CityName
+	}
+	if *table != "" {
+		cfg.AWS.DynamoDBTable = *table
+	}
+	if cfg.AWS.DynamoDBTable == "" {
+		log.Fatal("A target table is required: set -table or DYNAMODB_TABLE")
+	}
+
+	if *startFlag == "" {
+		log.Fatal("-start is required")
+	}
+	start, err := time.Parse(time.RFC3339, *startFlag)
+	if err != nil {
+		log.Fatalf("Invalid -start: %v", err)
+	}
+	end := time.Now().UTC()
+	if *endFlag != "" {
+		if end, err = time.Parse(time.RFC3339, *endFlag); err != nil {
+			log.Fatalf("Invalid -end: %v", err)
+		}
+	}
+	if !start.Before(end) {
+		log.Fatal("-start must be before -end")
+	}
+	if *jobID == "" {
+		*jobID = fmt.Sprintf("%s-%s-%s-%s",
+			strings.ReplaceAll(strings.ToLower(*city), " ", "-"),
+			cfg.AWS.DynamoDBTable,
+			start.UTC().Format("20060102T150405Z"),
+			end.UTC().Format("20060102T150405Z"),
+		)
+	}
+
+	archiveCfg := config.LoadArchive()
+	keyLayout, err := handlers.ParseKeyLayout(archiveCfg.KeyLayout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.AWS.Region),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+
+	s3Handler := handlers.NewS3Handler(cfg, sess,
+		handlers.WithKeyLayout(keyLayout),
+		handlers.WithKeyPrefix(archiveCfg.KeyPrefix),
+	)
+	dynamoDBHandler, err := handlers.NewDynamoDBHandler(cfg, sess)
+	if err != nil {
+		log.Fatalf("Failed to create DynamoDB handler: %v", err)
+	}
+
+	rehydrator := jobs.NewRehydrator(s3Handler, dynamoDBHandler, services.NewWeatherService(cfg),
+		jobs.NewCheckpointStore(s3Handler, jobs.DefaultCheckpointPrefix, "rehydrate"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Rehydrating %s from %s to %s into %s (job %s)",
+		*city, start.Format(time.RFC3339), end.Format(time.RFC3339), cfg.AWS.DynamoDBTable, *jobID)
+	report, err := rehydrator.Run(ctx, *jobID, jobs.RehydrateOptions{
+		City:        *city,
+		Start:       start,
+		End:         end,
+		BatchSize:   *batchSize,
+		TTLDays:     *ttlDays,
+		Concurrency: *concurrency,
+	})
+	if err != nil {
+		if report != nil {
+			log.Printf("Stopped after %d records written by this run (%d in total); rerun with -job %s to resume after %s",
+				report.Written, report.Job.Written, *jobID, report.Job.LastKey)
+		}
+		log.Fatalf("Rehydrate failed: %v", err)
+	}
+
+	log.Printf("Rehydrate complete. This run: %d archived objects read, %d records written, %d skipped, %d unreadable",
+		report.Processed, report.Written, report.Skipped, report.Unreadable)
+	if report.Resumed || report.Job.Processed != report.Processed {
+		log.Printf("Job %s total: %d archived objects read, %d records written, %d skipped, %d unreadable",
+			*jobID, report.Job.Processed, report.Job.Written, report.Job.Skipped, report.Job.Unreadable)
+	}
+}
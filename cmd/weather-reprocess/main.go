@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/jobs"
+	"github.com/weather-lambda/internal/services"
+)
+
+// Re-derives archived weather records with the current conversion logic and rewrites the ones that changed
+func main() {
+	city := flag.String("city", "", "City to reprocess (defaults to all cities)")
+	startFlag := flag.String("start", "", "Start of the observation range, RFC3339 (defaults to the beginning of the archive)")
+	endFlag := flag.String("end", "", "End of the observation range, RFC3339 (defaults to now)")
+	dryRun := flag.Bool("dry-run", false, "Report what would change without writing")
+	reportPath := flag.String("report", "-", "Write the JSON report to this file (- for stdout)")
+	maxDiffs := flag.Int("max-diffs", 1000, "Maximum record diffs kept in the report (0 = no limit)")
+	jobID := flag.String("job", "", "Job ID used for checkpointing (defaults to one derived from the city and range)")
+	concurrency := flag.Int("concurrency", handlers.DefaultIteratorConcurrency, "Concurrent archive reads")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.AWS.S3Bucket == "" {
+		log.Fatal("S3_BUCKET environment variable is required")
+	}
+	if cfg.AWS.DynamoDBTable == "" {
+		log.Fatal("DYNAMODB_TABLE environment variable is required")
+	}
+
+	var start time.Time
+	if *startFlag != "" {
+		if start, err = time.Parse(time.RFC3339, *startFlag); err != nil {
+			log.Fatalf("Invalid -start: %v", err)
+		}
+	}
+	end := time.Now().UTC()
+	if *endFlag != "" {
+		if end, err = time.Parse(time.RFC3339, *endFlag); err != nil {
+			log.Fatalf("Invalid -end: %v", err)
+		}
+	}
+	if *jobID == "" {
+		scope := "all"
+		if *city != "" {
+			scope = strings.ReplaceAll(strings.ToLower(*city), " ", "-")
+		}
+		*jobID = fmt.Sprintf("%s-%s-%s", scope, start.UTC().Format("20060102T150405Z"), end.UTC().Format("20060102T150405Z"))
+	}
+
+	archiveCfg := config.LoadArchive()
+	keyLayout, err := handlers.ParseKeyLayout(archiveCfg.KeyLayout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.AWS.Region),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+
+	s3Handler := handlers.NewS3Handler(cfg, sess,
+		handlers.WithKeyLayout(keyLayout),
+		handlers.WithKeyPrefix(archiveCfg.KeyPrefix),
+	)
+	dynamoDBHandler, err := handlers.NewDynamoDBHandler(cfg, sess)
+	if err != nil {
+		log.Fatalf("Failed to create DynamoDB handler: %v", err)
+	}
+
+	reprocessor := jobs.NewReprocessor(s3Handler, dynamoDBHandler, services.NewWeatherService(cfg),
+		jobs.NewCheckpointStore(s3Handler, jobs.DefaultCheckpointPrefix, "reprocess"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Reprocessing archived records (job %s, dry run: %t)", *jobID, *dryRun)
+	report, runErr := reprocessor.Run(ctx, *jobID, jobs.ReprocessOptions{
+		City:        *city,
+		Start:       start,
+		End:         end,
+		DryRun:      *dryRun,
+		MaxDiffs:    *maxDiffs,
+		Concurrency: *concurrency,
+	})
+	if report != nil {
+		if err := writeReport(*reportPath, report); err != nil {
+			log.Printf("Failed to write report: %v", err)
+		}
+		log.Printf("Reprocess summary: %d processed, %d changed, %d unchanged, %d skipped, %d unreadable (fields: %v)",
+			report.Processed, report.Changed, report.Unchanged, report.Skipped, report.Unreadable, report.FieldCounts)
+	}
+	if runErr != nil {
+		log.Fatalf("Reprocess failed: %v", runErr)
+	}
+}
+
+// writeReport writes the report as indented JSON to path, or stdout for "-"
+func writeReport(path string, report *jobs.ReprocessReport) error {
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	body = append(body, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	return os.WriteFile(path, body, 0o644)
+}
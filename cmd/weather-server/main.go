@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/weather-lambda/internal/api"
+	"github.com/weather-lambda/internal/collector"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/schedule"
+	"github.com/weather-lambda/internal/server"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("Weather server failed: %v", err)
+	}
+}
+
+// run wires the collector and history API to the scheduler and HTTP server until SIGINT or SIGTERM
+func run() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	serverCfg := config.LoadServer()
+
+	opts, err := handlers.ArchiveOptions(config.LoadArchive())
+	if err != nil {
+		return err
+	}
+	stores, err := handlers.NewStores(cfg, config.LoadStorage(), opts...)
+	if err != nil {
+		return err
+	}
+	defer stores.Close()
+
+	collectorHandler, err := collector.NewHandler(cfg, stores)
+	if err != nil {
+		return err
+	}
+	historyHandler := api.NewHandler(cfg, stores)
+
+	scheduler := schedule.NewScheduler()
+	if err := addEventJob(scheduler, collectorHandler, serverCfg.CollectSchedule, collector.ActionCollect); err != nil {
+		return err
+	}
+	if serverCfg.CollectSchedule != config.ScheduleOff {
+		// The collect schedule is the collector's tick, so the two can't disagree
+		sched, err := schedule.Parse(serverCfg.CollectSchedule)
+		if err != nil {
+			return err
+		}
+		if err := collectorHandler.SetTickSchedule(sched); err != nil {
+			return err
+		}
+	}
+	if err := addEventJob(scheduler, collectorHandler, serverCfg.CompactSchedule, collector.ActionCompact); err != nil {
+		return err
+	}
+	if err := addEventJob(scheduler, collectorHandler, serverCfg.RollupSchedule, collector.ActionRollup); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	historyAPI := server.RequireAPIKey(serverCfg.APIKey, server.APIGatewayHandler(historyHandler.HandleRequest))
+	mux.Handle("/weather/history", historyAPI)
+	mux.Handle("/weather/current", historyAPI)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	if serverCfg.APIKey == "" {
+		log.Printf("SERVER_API_KEY is not set; any X-Api-Key value is accepted")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	schedulerDone := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(schedulerDone)
+	}()
+
+	httpServer := &http.Server{
+		Addr:              serverCfg.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Weather server listening on %s", serverCfg.Addr)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Printf("Shutting down weather server")
+	case err = <-serverErr:
+		stop()
+		err = fmt.Errorf("HTTP server failed: %w", err)
+	}
+
+	// Drain in-flight requests and let a running collection finish before closing the stores
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverCfg.ShutdownTimeout)
+	defer cancel()
+	if shutdownErr := httpServer.Shutdown(shutdownCtx); shutdownErr != nil {
+		log.Printf("Error shutting down HTTP server: %v", shutdownErr)
+	}
+	select {
+	case <-schedulerDone:
+	case <-shutdownCtx.Done():
+		log.Printf("Timed out waiting for scheduled jobs to finish")
+	}
+
+	return err
+}
+
+// addEventJob schedules a collector action, invoking the handler with the same event an
+// EventBridge rule would send. A schedule of "off" skips the job.
+func addEventJob(scheduler *schedule.Scheduler, handler *collector.Handler, expr, action string) error {
+	if expr == config.ScheduleOff {
+		return nil
+	}
+	sched, err := schedule.Parse(expr)
+	if err != nil {
+		return err
+	}
+
+	event, err := json.Marshal(collector.WeatherEvent{Action: action})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", action, err)
+	}
+	scheduler.Add(action, sched, func(ctx context.Context) {
+		response, err := handler.HandleRequest(ctx, event)
+		if err != nil {
+			log.Printf("Scheduled %s failed: %v", action, err)
+			return
+		}
+		log.Printf("Scheduled %s finished with status %d: %s", action, response.StatusCode, response.Message)
+	})
+	log.Printf("Scheduled %s on %q", action, expr)
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package aggregates maintains hourly and daily per-city aggregates from the records table's stream
+package aggregates
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/metrics"
+	"github.com/weather-lambda/internal/models"
+)
+
+// UpdateFailuresMetric counts aggregates that couldn't be updated and are left for a retry
+const UpdateFailuresMetric = "AggregateUpdateFailures"
+
+// Store applies record changes to aggregates; DynamoDBHandler implements it
+type Store interface {
+	ApplyAggregateChanges(ctx context.Context, city, period, start string, changes []handlers.AggregateChange) error
+}
+
+// Handler processes batches from the records table's stream
+type Handler struct {
+	store Store
+}
+
+// NewHandler creates a handler updating aggregates in store
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+// bucket identifies one aggregate
+type bucket struct {
+	city, period, start string
+}
+
+// periods lists the aggregate periods with the layout of their start keys
+var periods = []struct {
+	period, layout string
+}{
+	{models.AggregateHourly, "2006-01-02T15"},
+	{models.AggregateDaily, "2006-01-02"},
+}
+
+// group collects a bucket's changes in stream order with the records they came from
+type group struct {
+	bucket
+	changes   []handlers.AggregateChange
+	sequences []string
+}
+
+// HandleRequest applies a stream batch to the aggregates. Records whose aggregates fail to
+// update are reported as batch item failures so Lambda retries them; redelivered records that
+// were already counted are skipped, which makes the retry safe.
+func (h *Handler) HandleRequest(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	var groups []*group
+	index := make(map[bucket]*group)
+	for _, record := range event.Records {
+		// Removals are TTL expiry; aggregates outlive the records they summarize
+		if record.EventName == string(events.DynamoDBOperationTypeRemove) {
+			continue
+		}
+
+		current, err := handlers.DecodeStreamImage(record.Change.NewImage)
+		if err != nil {
+			log.Printf("Skipping undecodable stream record %s: %v", record.EventID, err)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		var previous *models.WeatherRecord
+		if record.EventName == string(events.DynamoDBOperationTypeModify) {
+			if previous, err = handlers.DecodeStreamImage(record.Change.OldImage); err != nil {
+				log.Printf("Ignoring undecodable old image of %s: %v", record.EventID, err)
+				previous = nil
+			}
+		}
+
+		add := func(b bucket, change handlers.AggregateChange) {
+			g, ok := index[b]
+			if !ok {
+				g = &group{bucket: b}
+				index[b] = g
+				groups = append(groups, g)
+			}
+			g.changes = append(g.changes, change)
+			g.sequences = append(g.sequences, record.Change.SequenceNumber)
+		}
+
+		key := current.ID + "@" + current.Timestamp
+		values := aggregateValues(current)
+		var previousValues models.AggregateValues
+		if previous != nil {
+			previousValues = aggregateValues(previous)
+		}
+		for _, p := range periods {
+			start := current.ObservationTime().Format(p.layout)
+			change := handlers.AggregateChange{Key: key, Values: values}
+			if previous != nil {
+				if previousStart := previous.ObservationTime().Format(p.layout); previousStart == start && previous.CityName == current.CityName {
+					change.Previous = &previousValues
+				} else {
+					// The modification moved the record to another aggregate; back it out of the old one
+					add(bucket{previous.CityName, p.period, previousStart}, handlers.AggregateChange{Key: key, Previous: &previousValues, Removed: true})
+				}
+			}
+			add(bucket{current.CityName, p.period, start}, change)
+		}
+	}
+
+	var response events.DynamoDBEventResponse
+	failed := make(map[string]bool)
+	for _, g := range groups {
+		if err := h.store.ApplyAggregateChanges(ctx, g.city, g.period, g.start, g.changes); err != nil {
+			log.Printf("Failed to update %s aggregate %s/%s: %v", g.period, g.city, g.start, err)
+			metrics.Count(UpdateFailuresMetric, 1, map[string]string{"Period": g.period})
+			for _, sequence := range g.sequences {
+				if !failed[sequence] {
+					failed[sequence] = true
+					response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{ItemIdentifier: sequence})
+				}
+			}
+		}
+	}
+	return response, nil
+}
+
+func aggregateValues(record *models.WeatherRecord) models.AggregateValues {
+	return models.AggregateValues{
+		Temperature:   record.Temperature,
+		Humidity:      float64(record.Humidity),
+		Pressure:      float64(record.Pressure),
+		WindSpeed:     record.WindSpeed,
+		Precipitation: record.Precipitation,
+	}
+}
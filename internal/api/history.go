@@ -0,0 +1,315 @@
+// Package api implements the weather history API handler shared by the Lambda and the self-hosted server
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/history"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// Handler serves weather history requests
+type Handler struct {
+	records        storage.RecordStore
+	latest         storage.LatestStore
+	historyService *history.Service
+	config         *config.Config
+	historyConfig  config.HistoryConfig
+}
+
+// WeatherHistoryResponse is the body of a successful history response
+type WeatherHistoryResponse struct {
+	StatusCode int                    `json:"statusCode"`
+	Message    string                 `json:"message"`
+	Data       []models.WeatherRecord `json:"data"`
+	Count      int                    `json:"count"`
+	Period     string                 `json:"period"`
+	StartTime  string                 `json:"startTime"`
+	EndTime    string                 `json:"endTime"`
+	Sources    []string               `json:"sources"`
+	// Summaries covers the days older than the record retention window
+	Summaries []models.DailySummary `json:"summaries,omitempty"`
+	// Unreadable counts archived readings missing from Data because they couldn't be decoded
+	Unreadable int `json:"unreadable,omitempty"`
+	// MissingDays lists days older than the retention window with no summary or archive to serve them
+	MissingDays []string `json:"missingDays,omitempty"`
+}
+
+// API-level error codes; failures from the stores and services use the errs codes
+const (
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeUnauthorized     = "unauthorized"
+)
+
+// ErrorResponse is the body of a failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code is a stable machine-readable error code
+	Code string `json:"code"`
+}
+
+// CurrentConditionsResponse is the body of a successful current-conditions response
+type CurrentConditionsResponse struct {
+	StatusCode int                   `json:"statusCode"`
+	Message    string                `json:"message"`
+	City       string                `json:"city"`
+	Data       *models.WeatherRecord `json:"data"`
+}
+
+// NewHandler creates a handler over the given stores
+func NewHandler(cfg *config.Config, stores *handlers.Stores) *Handler {
+	// Ranges older than the record retention window are served from the archive
+	historyCfg := config.LoadHistory()
+	s3Handler := stores.Archive
+	if !historyCfg.ArchiveFallback {
+		s3Handler = nil
+	}
+
+	historyService := history.NewService(stores.Records, s3Handler, historyCfg.Retention.For, historyCfg.ArchiveConcurrency)
+	if historyCfg.DailySummaries && stores.Summaries != nil {
+		historyService.SetSummaries(stores.Summaries)
+	}
+
+	return &Handler{
+		records:        stores.Records,
+		latest:         stores.Latest,
+		historyService: historyService,
+		config:         cfg,
+		historyConfig:  historyCfg,
+	}
+}
+
+// HandleRequest handles an API Gateway proxy request
+func (h *Handler) HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// CORS headers
+	headers := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
+		"Access-Control-Allow-Methods": "GET,OPTIONS",
+		"Content-Type":                 "application/json",
+	}
+
+	// Handle OPTIONS request for CORS preflight
+	if request.HTTPMethod == "OPTIONS" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers:    headers,
+			Body:       "",
+		}, nil
+	}
+
+	// Only allow GET requests
+	if request.HTTPMethod != "GET" {
+		return errorResponse(headers, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed"), nil
+	}
+
+	// Security validation: Check for API key presence (API Gateway handles validation)
+	if request.Headers["X-API-Key"] == "" && request.Headers["x-api-key"] == "" {
+		log.Printf("Missing API key in request")
+		return errorResponse(headers, http.StatusUnauthorized, CodeUnauthorized, "API key required"), nil
+	}
+
+	if strings.HasSuffix(request.Path, "/current") {
+		return h.handleCurrent(ctx, request, headers), nil
+	}
+
+	// Input sanitization and validation
+	period := request.QueryStringParameters["period"]
+	if period == "" {
+		period = "6h" // Default to 6 hours
+	}
+
+	// Validate period parameter to prevent injection
+	if !isValidPeriod(period) {
+		return errorResponse(headers, http.StatusBadRequest, errs.CodeValidation, "Invalid period parameter"), nil
+	}
+
+	city := request.QueryStringParameters["city"]
+	if city == "" {
+		city = h.config.Weather.CityName // Use default city from config
+	}
+
+	// Sanitize city name to prevent injection
+	city = sanitizeCityName(city)
+
+	start := request.QueryStringParameters["start"]
+	startTime, endTime, rangeErr := h.resolveTimeRange(period, start, request.QueryStringParameters["end"])
+	if rangeErr != nil {
+		return failureResponse(headers, rangeErr, "Invalid time range"), nil
+	}
+
+	result, err := h.historyService.Query(ctx, city, startTime, endTime)
+	if err != nil {
+		log.Printf("Error getting weather history: %v", err)
+		return failureResponse(headers, err, "Failed to retrieve weather history"), nil
+	}
+	records := result.Records
+	if start != "" {
+		period = "custom"
+	}
+
+	response := WeatherHistoryResponse{
+		StatusCode:  http.StatusOK,
+		Message:     "Weather history retrieved successfully",
+		Data:        records,
+		Count:       len(records),
+		Period:      period,
+		StartTime:   startTime.Format(time.RFC3339),
+		EndTime:     endTime.Format(time.RFC3339),
+		Sources:     result.Sources,
+		Summaries:   result.Summaries,
+		Unreadable:  result.Unreadable,
+		MissingDays: result.MissingDays,
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error marshaling response: %v", err)
+		return errorResponse(headers, http.StatusInternalServerError, errs.CodeInternal, "Failed to create response"), nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       string(responseBody),
+	}, nil
+}
+
+// handleCurrent serves the city's latest reading
+func (h *Handler) handleCurrent(ctx context.Context, request events.APIGatewayProxyRequest, headers map[string]string) events.APIGatewayProxyResponse {
+	city := request.QueryStringParameters["city"]
+	if city == "" {
+		city = h.config.Weather.CityName
+	}
+	city = sanitizeCityName(city)
+
+	record, err := h.latest.GetLatestWeather(ctx, city)
+	if errors.Is(err, errs.ErrNotFound) {
+		return errorResponse(headers, http.StatusNotFound, errs.CodeNotFound, "No readings for city")
+	}
+	if err != nil {
+		log.Printf("Error getting latest weather: %v", err)
+		return failureResponse(headers, err, "Failed to retrieve current conditions")
+	}
+
+	body, err := json.Marshal(CurrentConditionsResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Current conditions retrieved successfully",
+		City:       city,
+		Data:       record,
+	})
+	if err != nil {
+		log.Printf("Error marshaling response: %v", err)
+		return errorResponse(headers, http.StatusInternalServerError, errs.CodeInternal, "Failed to create response")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       string(body),
+	}
+}
+
+// errorResponse builds a failed response with the given status and error code
+func errorResponse(headers map[string]string, statusCode int, code, message string) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(ErrorResponse{Error: message, Code: code})
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    headers,
+		Body:       string(body),
+	}
+}
+
+// failureResponse maps a store or service error to its status and code. Validation messages
+// are returned to the caller; other classes get a fixed message so internals don't leak.
+func failureResponse(headers map[string]string, err error, message string) events.APIGatewayProxyResponse {
+	code := errs.Code(err)
+	switch code {
+	case errs.CodeValidation:
+		message = err.Error()
+	case errs.CodeThrottled:
+		message = "Too many requests, please retry later"
+	case errs.CodeUpstreamUnavailable:
+		message = "A backing service is unavailable, please retry later"
+	case errs.CodeConflict:
+		message = "Conflicting update, please retry"
+	}
+	return errorResponse(headers, errs.HTTPStatus(err), code, message)
+}
+
+// isValidPeriod validates the period parameter to prevent injection attacks
+func isValidPeriod(period string) bool {
+	// Allow specific formats: 6h, 24h, 1d, a number of hours or a number of days (e.g. 30d)
+	matched, _ := regexp.MatchString(`^(6h|24h|1d|[1-9][0-9]{0,4}|[1-9][0-9]{0,3}d)$`, period)
+	return matched
+}
+
+// resolveTimeRange converts the period, or explicit RFC 3339 start/end parameters, into a time range
+func (h *Handler) resolveTimeRange(period, start, end string) (time.Time, time.Time, error) {
+	maxPeriod := h.historyConfig.MaxPeriod
+	endTime := time.Now()
+
+	if start != "" {
+		startTime, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return time.Time{}, time.Time{}, errs.Invalid("start", "invalid start, expected RFC 3339")
+		}
+		if end != "" {
+			if endTime, err = time.Parse(time.RFC3339, end); err != nil {
+				return time.Time{}, time.Time{}, errs.Invalid("end", "invalid end, expected RFC 3339")
+			}
+		}
+		if !startTime.Before(endTime) {
+			return time.Time{}, time.Time{}, errs.Invalid("start", "start must be before end")
+		}
+		if endTime.Sub(startTime) > maxPeriod {
+			return time.Time{}, time.Time{}, errs.Invalid("start", fmt.Sprintf("time range exceeds the maximum of %d hours", int(maxPeriod.Hours())))
+		}
+		return startTime, endTime, nil
+	}
+
+	var duration time.Duration
+	switch {
+	case period == "6h":
+		duration = 6 * time.Hour
+	case period == "24h", period == "1d":
+		duration = 24 * time.Hour
+	case strings.HasSuffix(period, "d"):
+		days, _ := strconv.Atoi(strings.TrimSuffix(period, "d"))
+		duration = time.Duration(days) * 24 * time.Hour
+	default:
+		hours, _ := strconv.Atoi(period)
+		duration = time.Duration(hours) * time.Hour
+	}
+
+	if duration <= 0 || duration > maxPeriod {
+		return time.Time{}, time.Time{}, errs.Invalid("period", fmt.Sprintf("invalid period. Use '6h', '24h', a number of hours or days (e.g. '30d') up to %d hours", int(maxPeriod.Hours())))
+	}
+
+	return endTime.Add(-duration), endTime, nil
+}
+
+// sanitizeCityName sanitizes city name input to prevent injection
+func sanitizeCityName(city string) string {
+	// Remove potential harmful characters and limit length
+	city = strings.TrimSpace(city)
+	city = regexp.MustCompile(`[^a-zA-Z\s\-]`).ReplaceAllString(city, "")
+	if len(city) > 50 {
+		city = city[:50]
+	}
+	return city
+}
@@ -0,0 +1,447 @@
+// Package awsfake provides in-memory fakes of the DynamoDB and S3 clients the handlers use,
+// so handler logic can be tested offline. They implement the handlers.DynamoDBClient and
+// handlers.S3Client interfaces, following the real services' semantics closely enough for
+// keys, conditions, pagination and errors to behave as they do in AWS.
+package awsfake
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// KeySchema names a table's or index's partition and sort key attributes
+type KeySchema struct {
+	Hash  string
+	Range string
+}
+
+// DynamoDB is an in-memory DynamoDB client. Every table it is asked about shares one key
+// schema and set of global secondary indexes.
+type DynamoDB struct {
+	// Fail, when set, is called before every request with the operation name, such as
+	// "PutItem"; a non-nil error fails the request. Use it to inject throttling.
+	Fail func(operation string) error
+	// BatchWriteLimit, when positive, caps the requests each BatchWriteItem call applies; the
+	// rest come back as UnprocessedItems, as they do when a table is short of capacity
+	BatchWriteLimit int
+
+	mu      sync.Mutex
+	key     KeySchema
+	indexes map[string]KeySchema
+	tables  map[string]map[string]map[string]*dynamodb.AttributeValue
+}
+
+// NewDynamoDB creates an empty fake whose tables have the given key and indexes
+func NewDynamoDB(key KeySchema, indexes map[string]KeySchema) *DynamoDB {
+	return &DynamoDB{
+		key:     key,
+		indexes: indexes,
+		tables:  make(map[string]map[string]map[string]*dynamodb.AttributeValue),
+	}
+}
+
+// Items returns copies of a table's items in key order
+func (d *DynamoDB) Items(table string) []map[string]*dynamodb.AttributeValue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	items := d.sorted(table, d.key)
+	for i, item := range items {
+		items[i] = copyItem(item)
+	}
+	return items
+}
+
+// GetItem reads one item by key
+func (d *DynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return d.GetItemWithContext(context.Background(), input)
+}
+
+// GetItemWithContext reads one item by key
+func (d *DynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if err := d.begin(ctx, "GetItem"); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, err := d.itemKey(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	expr := expression{names: input.ExpressionAttributeNames}
+	projection, err := expr.parseProjection(aws.StringValue(input.ProjectionExpression))
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	output := &dynamodb.GetItemOutput{}
+	if item, ok := d.table(aws.StringValue(input.TableName))[key]; ok {
+		output.Item = project(item, projection)
+	}
+	return output, nil
+}
+
+// PutItem writes an item, replacing any with the same key
+func (d *DynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return d.PutItemWithContext(context.Background(), input)
+}
+
+// PutItemWithContext writes an item if its condition holds, replacing any with the same key
+func (d *DynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if err := d.begin(ctx, "PutItem"); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, err := d.itemKey(input.Item)
+	if err != nil {
+		return nil, err
+	}
+	table := d.table(aws.StringValue(input.TableName))
+	expr := expression{names: input.ExpressionAttributeNames, values: input.ExpressionAttributeValues}
+	if err := checkCondition(expr, aws.StringValue(input.ConditionExpression), table[key]); err != nil {
+		return nil, err
+	}
+
+	table[key] = copyItem(input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// UpdateItemWithContext applies SET and REMOVE clauses to an item, creating it when missing
+func (d *DynamoDB) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	if err := d.begin(ctx, "UpdateItem"); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, err := d.itemKey(input.Key)
+	if err != nil {
+		return nil, err
+	}
+	table := d.table(aws.StringValue(input.TableName))
+	expr := expression{names: input.ExpressionAttributeNames, values: input.ExpressionAttributeValues}
+	if err := checkCondition(expr, aws.StringValue(input.ConditionExpression), table[key]); err != nil {
+		return nil, err
+	}
+	update, err := expr.parseUpdate(aws.StringValue(input.UpdateExpression))
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	item := copyItem(table[key])
+	if item == nil {
+		item = copyItem(input.Key)
+	}
+	update(item)
+	table[key] = item
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// BatchWriteItemWithContext applies puts and deletes, leaving any beyond BatchWriteLimit unprocessed
+func (d *DynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := d.begin(ctx, "BatchWriteItem"); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	output := &dynamodb.BatchWriteItemOutput{UnprocessedItems: make(map[string][]*dynamodb.WriteRequest)}
+	applied := 0
+	for name, requests := range input.RequestItems {
+		table := d.table(name)
+		for _, req := range requests {
+			if d.BatchWriteLimit > 0 && applied >= d.BatchWriteLimit {
+				output.UnprocessedItems[name] = append(output.UnprocessedItems[name], req)
+				continue
+			}
+			switch {
+			case req.PutRequest != nil:
+				key, err := d.itemKey(req.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				table[key] = copyItem(req.PutRequest.Item)
+			case req.DeleteRequest != nil:
+				key, err := d.itemKey(req.DeleteRequest.Key)
+				if err != nil {
+					return nil, err
+				}
+				delete(table, key)
+			}
+			applied++
+		}
+	}
+	return output, nil
+}
+
+// QueryWithContext reads one page of a partition of the table or a global secondary index
+func (d *DynamoDB) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	if err := d.begin(ctx, "Query"); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	schema := d.key
+	if input.IndexName != nil {
+		var ok bool
+		if schema, ok = d.indexes[*input.IndexName]; !ok {
+			return nil, validationError(fmt.Errorf("the table does not have the specified index: %s", *input.IndexName))
+		}
+	}
+
+	expr := expression{names: input.ExpressionAttributeNames, values: input.ExpressionAttributeValues}
+	keyCondition, err := expr.parseCondition(aws.StringValue(input.KeyConditionExpression))
+	if err != nil {
+		return nil, validationError(err)
+	}
+	filter, err := expr.parseCondition(aws.StringValue(input.FilterExpression))
+	if err != nil {
+		return nil, validationError(err)
+	}
+	projection, err := expr.parseProjection(aws.StringValue(input.ProjectionExpression))
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	var matched []map[string]*dynamodb.AttributeValue
+	for _, item := range d.sorted(aws.StringValue(input.TableName), schema) {
+		if keyCondition(item) {
+			matched = append(matched, item)
+		}
+	}
+	if input.ScanIndexForward != nil && !*input.ScanIndexForward {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+
+	items, count, scanned, last := d.page(matched, schema, input.ExclusiveStartKey, aws.Int64Value(input.Limit), filter, projection)
+	return &dynamodb.QueryOutput{Items: items, Count: aws.Int64(count), ScannedCount: aws.Int64(scanned), LastEvaluatedKey: last}, nil
+}
+
+// QueryPagesWithContext calls fn with each page of a query until fn returns false or the
+// results are exhausted
+func (d *DynamoDB) QueryPagesWithContext(ctx aws.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool, opts ...request.Option) error {
+	page := *input
+	for {
+		output, err := d.QueryWithContext(ctx, &page, opts...)
+		if err != nil {
+			return err
+		}
+		lastPage := len(output.LastEvaluatedKey) == 0
+		if !fn(output, lastPage) || lastPage {
+			return nil
+		}
+		page.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// ScanWithContext reads one page of the table, or of one segment of it
+func (d *DynamoDB) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	if err := d.begin(ctx, "Scan"); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expr := expression{names: input.ExpressionAttributeNames, values: input.ExpressionAttributeValues}
+	filter, err := expr.parseCondition(aws.StringValue(input.FilterExpression))
+	if err != nil {
+		return nil, validationError(err)
+	}
+	projection, err := expr.parseProjection(aws.StringValue(input.ProjectionExpression))
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	segment, total := aws.Int64Value(input.Segment), aws.Int64Value(input.TotalSegments)
+	var matched []map[string]*dynamodb.AttributeValue
+	for _, item := range d.sorted(aws.StringValue(input.TableName), d.key) {
+		if total > 1 && segmentOf(item[d.key.Hash], total) != segment {
+			continue
+		}
+		matched = append(matched, item)
+	}
+
+	items, count, scanned, last := d.page(matched, d.key, input.ExclusiveStartKey, aws.Int64Value(input.Limit), filter, projection)
+	return &dynamodb.ScanOutput{Items: items, Count: aws.Int64(count), ScannedCount: aws.Int64(scanned), LastEvaluatedKey: last}, nil
+}
+
+// begin checks the context and the injected failure before a request
+func (d *DynamoDB) begin(ctx aws.Context, operation string) error {
+	if err := ctx.Err(); err != nil {
+		return canceledError(err)
+	}
+	if d.Fail != nil {
+		return d.Fail(operation)
+	}
+	return nil
+}
+
+// page reads items after startKey up to limit evaluated items, applying the filter and
+// projection. Like DynamoDB, Limit counts items before filtering.
+func (d *DynamoDB) page(items []map[string]*dynamodb.AttributeValue, schema KeySchema, startKey map[string]*dynamodb.AttributeValue, limit int64, filter condition, projection []string) ([]map[string]*dynamodb.AttributeValue, int64, int64, map[string]*dynamodb.AttributeValue) {
+	start := 0
+	if len(startKey) > 0 {
+		if startAt, err := d.itemKey(startKey); err == nil {
+			for i, item := range items {
+				if key, _ := d.itemKey(item); key == startAt {
+					start = i + 1
+					break
+				}
+			}
+		}
+	}
+
+	var page []map[string]*dynamodb.AttributeValue
+	var scanned int64
+	var last map[string]*dynamodb.AttributeValue
+	for i := start; i < len(items); i++ {
+		if limit > 0 && scanned == limit {
+			last = d.lastKey(items[i-1], schema)
+			break
+		}
+		scanned++
+		if filter(items[i]) {
+			page = append(page, project(items[i], projection))
+		}
+	}
+	return page, int64(len(page)), scanned, last
+}
+
+// lastKey returns the LastEvaluatedKey for an item: the table key plus the index key
+func (d *DynamoDB) lastKey(item map[string]*dynamodb.AttributeValue, schema KeySchema) map[string]*dynamodb.AttributeValue {
+	key := make(map[string]*dynamodb.AttributeValue)
+	for _, name := range []string{d.key.Hash, d.key.Range, schema.Hash, schema.Range} {
+		if value, ok := item[name]; ok && name != "" {
+			key[name] = copyValue(value)
+		}
+	}
+	return key
+}
+
+// sorted returns a table's items that have the schema's key attributes, ordered by partition
+// and sort key, then by table key so the order is total
+func (d *DynamoDB) sorted(table string, schema KeySchema) []map[string]*dynamodb.AttributeValue {
+	var items []map[string]*dynamodb.AttributeValue
+	for _, item := range d.table(table) {
+		if item[schema.Hash] == nil || (schema.Range != "" && item[schema.Range] == nil) {
+			continue // Sparse index: items without the index key aren't in it
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		for _, name := range []string{schema.Hash, schema.Range, d.key.Hash, d.key.Range} {
+			if name == "" {
+				continue
+			}
+			if c, ok := compare(items[i][name], items[j][name]); ok && c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return items
+}
+
+func (d *DynamoDB) table(name string) map[string]map[string]*dynamodb.AttributeValue {
+	table, ok := d.tables[name]
+	if !ok {
+		table = make(map[string]map[string]*dynamodb.AttributeValue)
+		d.tables[name] = table
+	}
+	return table
+}
+
+// itemKey encodes the table key of an item or key map, rejecting missing key attributes
+func (d *DynamoDB) itemKey(item map[string]*dynamodb.AttributeValue) (string, error) {
+	key := ""
+	for _, name := range []string{d.key.Hash, d.key.Range} {
+		if name == "" {
+			continue
+		}
+		value := item[name]
+		if value == nil || (value.S == nil && value.N == nil && value.B == nil) {
+			return "", validationError(fmt.Errorf("missing key attribute %s", name))
+		}
+		key += scalarString(value) + "\x00"
+	}
+	return key, nil
+}
+
+// checkCondition evaluates a condition expression against the current item
+func checkCondition(expr expression, text string, current map[string]*dynamodb.AttributeValue) error {
+	if text == "" {
+		return nil
+	}
+	cond, err := expr.parseCondition(text)
+	if err != nil {
+		return validationError(err)
+	}
+	if current == nil {
+		current = map[string]*dynamodb.AttributeValue{}
+	}
+	if !cond(current) {
+		return conditionalCheckFailedError()
+	}
+	return nil
+}
+
+// segmentOf assigns a partition key to one of total scan segments
+func segmentOf(value *dynamodb.AttributeValue, total int64) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(scalarString(value)))
+	return int64(h.Sum32()) % total
+}
+
+func scalarString(value *dynamodb.AttributeValue) string {
+	switch {
+	case value == nil:
+		return ""
+	case value.S != nil:
+		return *value.S
+	case value.N != nil:
+		return *value.N
+	}
+	return string(value.B)
+}
+
+// project keeps the named top-level attributes of a copy of item; nil keeps them all
+func project(item map[string]*dynamodb.AttributeValue, names []string) map[string]*dynamodb.AttributeValue {
+	if names == nil {
+		return copyItem(item)
+	}
+	projected := make(map[string]*dynamodb.AttributeValue, len(names))
+	for _, name := range names {
+		if value, ok := item[name]; ok {
+			projected[name] = copyValue(value)
+		}
+	}
+	return projected
+}
+
+func copyItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	copied := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, value := range item {
+		copied[name] = copyValue(value)
+	}
+	return copied
+}
+
+func copyValue(value *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	return awsutil.CopyOf(value).(*dynamodb.AttributeValue)
+}
@@ -0,0 +1,52 @@
+package awsfake
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ThrottlingError returns the error DynamoDB sends when a table is short of capacity
+func ThrottlingError() error {
+	return awserr.NewRequestFailure(
+		awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "the level of configured provisioned throughput for the table was exceeded", nil),
+		http.StatusBadRequest, "fake-request",
+	)
+}
+
+// UnavailableError returns the error a service sends when it is temporarily unavailable
+func UnavailableError() error {
+	return awserr.NewRequestFailure(
+		awserr.New("ServiceUnavailable", "service unavailable", nil),
+		http.StatusServiceUnavailable, "fake-request",
+	)
+}
+
+func conditionalCheckFailedError() error {
+	return awserr.NewRequestFailure(
+		awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "the conditional request failed", nil),
+		http.StatusBadRequest, "fake-request",
+	)
+}
+
+func validationError(err error) error {
+	return awserr.NewRequestFailure(
+		awserr.New("ValidationException", err.Error(), err),
+		http.StatusBadRequest, "fake-request",
+	)
+}
+
+func noSuchKeyError(key string) error {
+	return awserr.NewRequestFailure(
+		awserr.New(s3.ErrCodeNoSuchKey, fmt.Sprintf("the specified key does not exist: %s", key), nil),
+		http.StatusNotFound, "fake-request",
+	)
+}
+
+func canceledError(err error) error {
+	return awserr.New(request.CanceledErrorCode, "request context canceled", err)
+}
@@ -0,0 +1,421 @@
+package awsfake
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// condition is a parsed condition, filter or key condition expression
+type condition func(item map[string]*dynamodb.AttributeValue) bool
+
+// operand resolves to an attribute of the item or an expression value; nil when missing
+type operand func(item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue
+
+// expression holds the attribute names and values an expression refers to
+type expression struct {
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+}
+
+// parseCondition parses the subset of the DynamoDB condition syntax the handlers use:
+// comparisons, BETWEEN, IN, AND/OR/NOT, parentheses, attribute_exists,
+// attribute_not_exists and begins_with. An empty expression matches every item.
+func (e expression) parseCondition(text string) (condition, error) {
+	if strings.TrimSpace(text) == "" {
+		return func(map[string]*dynamodb.AttributeValue) bool { return true }, nil
+	}
+	p := &parser{expr: e, tokens: tokenize(text)}
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q in expression %q", p.peek(), text)
+	}
+	return cond, nil
+}
+
+// name resolves a path token to its attribute name
+func (e expression) name(token string) (string, error) {
+	if strings.HasPrefix(token, "#") {
+		name, ok := e.names[token]
+		if !ok || name == nil {
+			return "", fmt.Errorf("undefined attribute name %s", token)
+		}
+		return *name, nil
+	}
+	return token, nil
+}
+
+// parseProjection returns the top-level attributes a projection expression keeps, or nil for all
+func (e expression) parseProjection(text string) ([]string, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	var names []string
+	for _, part := range strings.Split(text, ",") {
+		name, err := e.name(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// parseUpdate parses SET and REMOVE clauses into a function applying them to an item
+func (e expression) parseUpdate(text string) (func(item map[string]*dynamodb.AttributeValue), error) {
+	type assignment struct {
+		name  string
+		value operand
+	}
+	var sets []assignment
+	var removes []string
+
+	tokens := tokenize(text)
+	clause := ""
+	for i := 0; i < len(tokens); i++ {
+		switch upper := strings.ToUpper(tokens[i]); {
+		case upper == "SET" || upper == "REMOVE":
+			clause = upper
+		case tokens[i] == ",":
+		case clause == "SET":
+			if i+2 >= len(tokens) || tokens[i+1] != "=" {
+				return nil, fmt.Errorf("invalid SET clause in %q", text)
+			}
+			name, err := e.name(tokens[i])
+			if err != nil {
+				return nil, err
+			}
+			value, err := e.operand(tokens[i+2])
+			if err != nil {
+				return nil, err
+			}
+			sets = append(sets, assignment{name, value})
+			i += 2
+		case clause == "REMOVE":
+			name, err := e.name(tokens[i])
+			if err != nil {
+				return nil, err
+			}
+			removes = append(removes, name)
+		default:
+			return nil, fmt.Errorf("unsupported update expression %q", text)
+		}
+	}
+
+	return func(item map[string]*dynamodb.AttributeValue) {
+		for _, set := range sets {
+			item[set.name] = set.value(item)
+		}
+		for _, name := range removes {
+			delete(item, name)
+		}
+	}, nil
+}
+
+// operand parses a path or :value token
+func (e expression) operand(token string) (operand, error) {
+	if strings.HasPrefix(token, ":") {
+		value, ok := e.values[token]
+		if !ok || value == nil {
+			return nil, fmt.Errorf("undefined attribute value %s", token)
+		}
+		return func(map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue { return value }, nil
+	}
+	if !isPath(token) {
+		return nil, fmt.Errorf("invalid operand %q", token)
+	}
+	name, err := e.name(token)
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue { return item[name] }, nil
+}
+
+type parser struct {
+	expr   expression
+	tokens []string
+	pos    int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *parser) keyword(word string) bool {
+	if strings.EqualFold(p.peek(), word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+func (p *parser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]*dynamodb.AttributeValue) bool { return l(item) || right(item) }
+	}
+	return left, nil
+}
+
+func (p *parser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]*dynamodb.AttributeValue) bool { return l(item) && right(item) }
+	}
+	return left, nil
+}
+
+func (p *parser) not() (condition, error) {
+	if p.keyword("NOT") {
+		inner, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]*dynamodb.AttributeValue) bool { return !inner(item) }, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (condition, error) {
+	if p.peek() == "(" {
+		p.next()
+		cond, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expect(")")
+	}
+
+	switch function := strings.ToLower(p.peek()); function {
+	case "attribute_exists", "attribute_not_exists":
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		name, err := p.expr.name(p.next())
+		if err != nil {
+			return nil, err
+		}
+		want := function == "attribute_exists"
+		return func(item map[string]*dynamodb.AttributeValue) bool {
+			_, ok := item[name]
+			return ok == want
+		}, p.expect(")")
+	case "begins_with":
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		path, err := p.expr.operand(p.next())
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		prefix, err := p.expr.operand(p.next())
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]*dynamodb.AttributeValue) bool {
+			value, want := path(item), prefix(item)
+			return value != nil && want != nil && value.S != nil && want.S != nil && strings.HasPrefix(*value.S, *want.S)
+		}, p.expect(")")
+	}
+
+	left, err := p.expr.operand(p.next())
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.keyword("BETWEEN"):
+		low, err := p.expr.operand(p.next())
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, fmt.Errorf("expected AND in BETWEEN")
+		}
+		high, err := p.expr.operand(p.next())
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]*dynamodb.AttributeValue) bool {
+			lo, ok := compare(left(item), low(item))
+			if !ok || lo < 0 {
+				return false
+			}
+			hi, ok := compare(left(item), high(item))
+			return ok && hi <= 0
+		}, nil
+	case p.keyword("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var candidates []operand
+		for {
+			candidate, err := p.expr.operand(p.next())
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, candidate)
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		return func(item map[string]*dynamodb.AttributeValue) bool {
+			for _, candidate := range candidates {
+				if c, ok := compare(left(item), candidate(item)); ok && c == 0 {
+					return true
+				}
+			}
+			return false
+		}, p.expect(")")
+	}
+
+	comparator := p.next()
+	right, err := p.expr.operand(p.next())
+	if err != nil {
+		return nil, err
+	}
+	var accept func(int) bool
+	switch comparator {
+	case "=":
+		accept = func(c int) bool { return c == 0 }
+	case "<>":
+		return func(item map[string]*dynamodb.AttributeValue) bool {
+			c, ok := compare(left(item), right(item))
+			return !ok || c != 0
+		}, nil
+	case "<":
+		accept = func(c int) bool { return c < 0 }
+	case "<=":
+		accept = func(c int) bool { return c <= 0 }
+	case ">":
+		accept = func(c int) bool { return c > 0 }
+	case ">=":
+		accept = func(c int) bool { return c >= 0 }
+	default:
+		return nil, fmt.Errorf("unsupported comparator %q", comparator)
+	}
+	return func(item map[string]*dynamodb.AttributeValue) bool {
+		c, ok := compare(left(item), right(item))
+		return ok && accept(c)
+	}, nil
+}
+
+// compare orders two scalar values of the same type; ok is false when they can't be compared
+func compare(a, b *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case a == nil || b == nil:
+		return 0, false
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), true
+	case a.N != nil && b.N != nil:
+		x, errX := strconv.ParseFloat(*a.N, 64)
+		y, errY := strconv.ParseFloat(*b.N, 64)
+		if errX != nil || errY != nil {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), true
+	case a.BOOL != nil && b.BOOL != nil:
+		if *a.BOOL == *b.BOOL {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// tokenize splits an expression into paths, placeholders, operators and punctuation
+func tokenize(text string) []string {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),", c):
+			tokens = append(tokens, string(c))
+			i++
+		case strings.ContainsRune("=<>", c):
+			j := i + 1
+			if j < len(text) && strings.ContainsRune("=>", rune(text[j])) && c != '=' {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(text) && !unicode.IsSpace(rune(text[j])) && !strings.ContainsRune("(),=<>", rune(text[j])) {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+func isPath(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, c := range token {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '#' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,260 @@
+package awsfake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3 is an in-memory S3 client holding objects for any number of buckets
+type S3 struct {
+	// Fail, when set, is called before every request with the operation name, such as
+	// "PutObject"; a non-nil error fails the request
+	Fail func(operation string) error
+	// Now stamps LastModified on stored objects; it defaults to time.Now
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]map[string]object
+}
+
+type object struct {
+	body            []byte
+	contentType     *string
+	contentEncoding *string
+	metadata        map[string]*string
+	lastModified    time.Time
+}
+
+// NewS3 creates an empty fake
+func NewS3() *S3 {
+	return &S3{buckets: make(map[string]map[string]object)}
+}
+
+// Keys returns a bucket's object keys in order
+func (s *S3) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedKeys(bucket)
+}
+
+// PutObjectWithContext stores an object, replacing any with the same key
+func (s *S3) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	if err := s.begin(ctx, "PutObject"); err != nil {
+		return nil, err
+	}
+	var body []byte
+	if input.Body != nil {
+		var err error
+		if body, err = io.ReadAll(input.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bucket(aws.StringValue(input.Bucket))[aws.StringValue(input.Key)] = object{
+		body:            body,
+		contentType:     copyString(input.ContentType),
+		contentEncoding: copyString(input.ContentEncoding),
+		metadata:        copyMetadata(input.Metadata),
+		lastModified:    s.now(),
+	}
+	return &s3.PutObjectOutput{}, nil
+}
+
+// GetObjectWithContext reads an object, failing with NoSuchKey when it doesn't exist
+func (s *S3) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := s.begin(ctx, "GetObject"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := aws.StringValue(input.Key)
+	obj, ok := s.bucket(aws.StringValue(input.Bucket))[key]
+	if !ok {
+		return nil, noSuchKeyError(key)
+	}
+	return &s3.GetObjectOutput{
+		Body:            io.NopCloser(bytes.NewReader(obj.body)),
+		ContentLength:   aws.Int64(int64(len(obj.body))),
+		ContentType:     copyString(obj.contentType),
+		ContentEncoding: copyString(obj.contentEncoding),
+		Metadata:        copyMetadata(obj.metadata),
+		LastModified:    aws.Time(obj.lastModified),
+	}, nil
+}
+
+// ListObjectsV2PagesWithContext calls fn with each page of a listing in key order, honouring
+// Prefix, StartAfter, Delimiter and MaxKeys
+func (s *S3) ListObjectsV2PagesWithContext(ctx aws.Context, input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	if err := s.begin(ctx, "ListObjectsV2"); err != nil {
+		return err
+	}
+	pages := s.listPages(input)
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// listPages builds every page of a listing up front, so fn can call back into the fake
+func (s *S3) listPages(input *s3.ListObjectsV2Input) []*s3.ListObjectsV2Output {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket := aws.StringValue(input.Bucket)
+	prefix := aws.StringValue(input.Prefix)
+	startAfter := aws.StringValue(input.StartAfter)
+	delimiter := aws.StringValue(input.Delimiter)
+	maxKeys := int(aws.Int64Value(input.MaxKeys))
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	var pages []*s3.ListObjectsV2Output
+	page := &s3.ListObjectsV2Output{}
+	entries := 0
+	seenPrefixes := make(map[string]bool)
+	for _, key := range s.sortedKeys(bucket) {
+		if !strings.HasPrefix(key, prefix) || key <= startAfter {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			common := key[:len(prefix)+i+len(delimiter)]
+			if seenPrefixes[common] {
+				continue
+			}
+			seenPrefixes[common] = true
+			page.CommonPrefixes = append(page.CommonPrefixes, &s3.CommonPrefix{Prefix: aws.String(common)})
+		} else {
+			obj := s.buckets[bucket][key]
+			page.Contents = append(page.Contents, &s3.Object{
+				Key:          aws.String(key),
+				Size:         aws.Int64(int64(len(obj.body))),
+				LastModified: aws.Time(obj.lastModified),
+			})
+		}
+		entries++
+		if entries == maxKeys {
+			page.KeyCount = aws.Int64(int64(entries))
+			page.IsTruncated = aws.Bool(true)
+			pages = append(pages, page)
+			page = &s3.ListObjectsV2Output{}
+			entries = 0
+		}
+	}
+	if entries > 0 || len(pages) == 0 {
+		page.KeyCount = aws.Int64(int64(entries))
+		page.IsTruncated = aws.Bool(false)
+		pages = append(pages, page)
+	} else {
+		pages[len(pages)-1].IsTruncated = aws.Bool(false)
+	}
+	return pages
+}
+
+// CopyObjectWithContext copies an object, keeping its metadata; CopySource is the
+// URL-escaped "bucket/key" of the source
+func (s *S3) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	if err := s.begin(ctx, "CopyObject"); err != nil {
+		return nil, err
+	}
+	source, err := url.PathUnescape(aws.StringValue(input.CopySource))
+	if err != nil {
+		return nil, validationError(err)
+	}
+	sourceBucket, sourceKey, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !ok {
+		return nil, validationError(fmt.Errorf("invalid copy source %q", source))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.bucket(sourceBucket)[sourceKey]
+	if !ok {
+		return nil, noSuchKeyError(sourceKey)
+	}
+	obj.body = append([]byte(nil), obj.body...)
+	obj.metadata = copyMetadata(obj.metadata)
+	obj.lastModified = s.now()
+	s.bucket(aws.StringValue(input.Bucket))[aws.StringValue(input.Key)] = obj
+	return &s3.CopyObjectOutput{}, nil
+}
+
+// DeleteObjectWithContext deletes an object; deleting a missing key succeeds, as in S3
+func (s *S3) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	if err := s.begin(ctx, "DeleteObject"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bucket(aws.StringValue(input.Bucket)), aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (s *S3) begin(ctx context.Context, operation string) error {
+	if err := ctx.Err(); err != nil {
+		return canceledError(err)
+	}
+	if s.Fail != nil {
+		return s.Fail(operation)
+	}
+	return nil
+}
+
+func (s *S3) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *S3) bucket(name string) map[string]object {
+	bucket, ok := s.buckets[name]
+	if !ok {
+		bucket = make(map[string]object)
+		s.buckets[name] = bucket
+	}
+	return bucket
+}
+
+func (s *S3) sortedKeys(bucket string) []string {
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	return aws.String(*value)
+}
+
+func copyMetadata(metadata map[string]*string) map[string]*string {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]*string, len(metadata))
+	for name, value := range metadata {
+		copied[name] = copyString(value)
+	}
+	return copied
+}
@@ -0,0 +1,565 @@
+// Package collector implements the weather collection handler shared by the Lambda and the self-hosted server
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/jobs"
+	"github.com/weather-lambda/internal/metrics"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/schedule"
+	"github.com/weather-lambda/internal/services"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// AdaptiveBoostsMetric counts sampling boosts triggered by rapid changes
+const AdaptiveBoostsMetric = "AdaptiveBoosts"
+
+// Supported WeatherEvent actions; an empty action collects weather data
+const (
+	ActionCollect = "collect"
+	ActionCompact = "compact"
+	// ActionRollup stores daily summaries of a day's records before they expire
+	ActionRollup = "rollup"
+	// ActionRecomputeTTL rewrites the TTL of stored records after the retention policy changes
+	ActionRecomputeTTL = "recompute-ttl"
+)
+
+// WeatherEvent represents a custom detail for weather collection
+type WeatherEvent struct {
+	Action string `json:"action,omitempty"`
+	// Date selects the UTC day (YYYY-MM-DD) for maintenance actions, defaulting to yesterday
+	Date string `json:"date,omitempty"`
+	// Cities selects the cities to act on, defaulting to the scheduled cities (only those due,
+	// for collection) or the configured city when there are no schedules
+	Cities          []string `json:"cities,omitempty"`
+	DeleteOriginals bool     `json:"deleteOriginals,omitempty"`
+	// Days extends rollup to this many days ending at Date, to summarize a backlog
+	Days int `json:"days,omitempty"`
+	// DryRun reports what recompute-ttl would change without writing
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// Response represents the output from the Lambda function
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// CityResult is one city's outcome when a tick collects several cities
+type CityResult struct {
+	City string `json:"city"`
+	*Response
+}
+
+// Handler collects weather data and runs maintenance actions
+type Handler struct {
+	weatherService *services.WeatherService
+	s3Handler      *handlers.S3Handler
+	records        storage.RecordStore
+	latest         storage.LatestStore
+	// dynamoDB is nil unless records are stored in DynamoDB
+	dynamoDB  *handlers.DynamoDBHandler
+	compactor *jobs.Compactor
+	// rollup is nil when the backend has no summary store
+	rollup *jobs.Rollup
+	config *config.Config
+	// plan is nil when no city schedules are configured
+	plan      *schedule.CityPlan
+	tick      time.Duration
+	adaptive  config.AdaptiveConfig
+	retention config.RetentionConfig
+}
+
+// NewHandler creates a handler over the given stores
+func NewHandler(cfg *config.Config, stores *handlers.Stores) (*Handler, error) {
+	// Validate required configuration
+	if cfg.Weather.APIKey == "" {
+		return nil, fmt.Errorf("WEATHER_API_KEY environment variable is required")
+	}
+	if stores.DynamoDB != nil {
+		if stores.Archive == nil {
+			return nil, fmt.Errorf("S3_BUCKET environment variable is required")
+		}
+		if cfg.AWS.DynamoDBTable == "" {
+			return nil, fmt.Errorf("DYNAMODB_TABLE environment variable is required")
+		}
+	}
+
+	archiveCfg := config.LoadArchive()
+	retentionCfg := config.LoadRetention()
+	weatherService := services.NewWeatherService(cfg)
+	weatherService.SetRetention(retentionCfg)
+
+	// Keep the last provider response per city to avoid storing unchanged observations
+	cacheCfg := config.LoadProviderCache()
+	switch cacheCfg.Backend {
+	case config.ProviderCacheS3:
+		weatherService.SetResponseCache(services.NewMemoryResponseCache(handlers.NewS3ResponseCache(stores.Archive, cacheCfg.Prefix)))
+	case config.ProviderCacheDynamoDB:
+		if stores.DynamoDB == nil {
+			return nil, fmt.Errorf("PROVIDER_CACHE_BACKEND=dynamodb requires STORAGE_BACKEND=aws")
+		}
+		weatherService.SetResponseCache(services.NewMemoryResponseCache(handlers.NewDynamoDBResponseCache(stores.DynamoDB)))
+	case config.ProviderCacheMemory:
+	default:
+		return nil, fmt.Errorf("unsupported PROVIDER_CACHE_BACKEND: %s", cacheCfg.Backend)
+	}
+
+	collectionCfg := config.LoadCollection()
+	plan, err := loadCityPlan(collectionCfg)
+	if err != nil {
+		return nil, err
+	}
+	adaptiveCfg := config.LoadAdaptive()
+	if adaptiveCfg.Enabled {
+		if adaptiveCfg.BoostInterval < time.Minute {
+			return nil, fmt.Errorf("ADAPTIVE_BOOST_INTERVAL_MINUTES must be at least 1")
+		}
+		if plan == nil {
+			log.Printf("ADAPTIVE_COLLECTION has no effect without CITY_SCHEDULES")
+		}
+	}
+
+	var rollup *jobs.Rollup
+	if stores.Summaries != nil {
+		rollup = jobs.NewRollup(stores.Records, stores.Summaries)
+	}
+
+	return &Handler{
+		weatherService: weatherService,
+		s3Handler:      stores.Archive,
+		records:        stores.Records,
+		latest:         stores.Latest,
+		dynamoDB:       stores.DynamoDB,
+		compactor:      jobs.NewCompactor(stores.Archive, archiveCfg.CuratedPrefix, archiveCfg.AllowDeleteOriginals),
+		rollup:         rollup,
+		config:         cfg,
+		plan:           plan,
+		tick:           collectionCfg.Tick,
+		adaptive:       adaptiveCfg,
+		retention:      retentionCfg,
+	}, nil
+}
+
+// loadCityPlan parses the configured city schedules, returning nil when there are none
+func loadCityPlan(collectionCfg config.CollectionConfig) (*schedule.CityPlan, error) {
+	data := []byte(collectionCfg.CitySchedules)
+	if collectionCfg.CitySchedulesFile != "" {
+		var err error
+		if data, err = os.ReadFile(collectionCfg.CitySchedulesFile); err != nil {
+			return nil, fmt.Errorf("failed to read CITY_SCHEDULES_FILE: %w", err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if collectionCfg.Tick <= 0 {
+		return nil, fmt.Errorf("COLLECT_TICK_MINUTES must be positive")
+	}
+
+	plan, err := schedule.ParseCitySchedules(data)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded collection schedules for %d cities (tick %s)", len(plan.Cities()), collectionCfg.Tick)
+	return plan, nil
+}
+
+// SetTickSchedule derives the tick from the schedule invoking the collector, replacing
+// COLLECT_TICK_MINUTES. City schedules need evenly spaced ticks to tell which cities are due.
+func (h *Handler) SetTickSchedule(sched schedule.Schedule) error {
+	tick, err := schedule.Interval(sched, time.Now())
+	if err != nil {
+		if h.plan == nil {
+			return nil
+		}
+		return fmt.Errorf("COLLECT_SCHEDULE can't drive CITY_SCHEDULES: %w", err)
+	}
+	if h.plan != nil && tick != h.tick {
+		log.Printf("Using collector tick %s from COLLECT_SCHEDULE instead of COLLECT_TICK_MINUTES (%s)", tick, h.tick)
+	}
+	h.tick = tick
+	return nil
+}
+
+// HandleRequest handles a collection request
+// Accepts both EventBridge CloudWatch Events and direct invocations
+func (h *Handler) HandleRequest(ctx context.Context, event json.RawMessage) (*Response, error) {
+	// Try to parse as CloudWatch Event first
+	var weatherEvent WeatherEvent
+	var cloudWatchEvent events.CloudWatchEvent
+	tickTime := time.Now()
+	if err := json.Unmarshal(event, &cloudWatchEvent); err == nil && cloudWatchEvent.Source != "" {
+		log.Printf("Received EventBridge event from source: %s", cloudWatchEvent.Source)
+		// Scheduled events carry the rule's trigger time, which is steadier than the invocation time
+		if !cloudWatchEvent.Time.IsZero() {
+			tickTime = cloudWatchEvent.Time
+		}
+		// EventBridge event - the detail may carry an action
+		if len(cloudWatchEvent.Detail) > 0 {
+			_ = json.Unmarshal(cloudWatchEvent.Detail, &weatherEvent)
+		}
+	} else {
+		// Might be direct invocation or other event type
+		log.Printf("Received direct invocation or unknown event type")
+		_ = json.Unmarshal(event, &weatherEvent)
+	}
+
+	switch weatherEvent.Action {
+	case "", ActionCollect:
+	case ActionCompact:
+		return h.handleCompact(ctx, weatherEvent), nil
+	case ActionRollup:
+		return h.handleRollup(ctx, weatherEvent), nil
+	case ActionRecomputeTTL:
+		return h.handleRecomputeTTL(ctx, weatherEvent), nil
+	default:
+		return &Response{
+			StatusCode: 400,
+			Message:    fmt.Sprintf("Unknown action: %s", weatherEvent.Action),
+		}, nil
+	}
+
+	return h.handleCollect(weatherEvent, tickTime.Truncate(time.Minute)), nil
+}
+
+// handleCollect collects the requested cities, or the cities due at tickTime when schedules are configured
+func (h *Handler) handleCollect(event WeatherEvent, tickTime time.Time) *Response {
+	cities := event.Cities
+	if len(cities) == 0 {
+		if h.plan == nil {
+			cities = []string{h.config.Weather.CityName}
+		} else {
+			cities = h.dueCities(tickTime)
+			log.Printf("%d of %d scheduled cities due at %s", len(cities), len(h.plan.Cities()), tickTime.Format(time.RFC3339))
+		}
+	}
+
+	switch len(cities) {
+	case 0:
+		return &Response{
+			StatusCode: 200,
+			Message:    "No cities due for collection",
+		}
+	case 1:
+		return h.collectCity(cities[0])
+	}
+
+	// One city failing doesn't stop the others; the tick reports each outcome
+	results := make([]CityResult, 0, len(cities))
+	failed := 0
+	for _, city := range cities {
+		response := h.collectCity(city)
+		if response.StatusCode != 200 {
+			failed++
+		}
+		results = append(results, CityResult{City: city, Response: response})
+	}
+
+	if failed > 0 {
+		return &Response{
+			StatusCode: 500,
+			Message:    fmt.Sprintf("Failed to collect %d of %d cities", failed, len(cities)),
+			Data:       results,
+		}
+	}
+	return &Response{
+		StatusCode: 200,
+		Message:    fmt.Sprintf("Weather data processed for %d cities", len(cities)),
+		Data:       results,
+	}
+}
+
+// dueCities returns the scheduled cities due at tickTime, plus boosted cities due on the boost interval
+func (h *Handler) dueCities(tickTime time.Time) []string {
+	due := h.plan.Due(tickTime, h.tick)
+	if !h.adaptive.Enabled {
+		return due
+	}
+
+	scheduled := make(map[string]bool, len(due))
+	for _, city := range due {
+		scheduled[city] = true
+	}
+	boost := schedule.Every(h.adaptive.BoostInterval)
+	for _, city := range h.plan.Cities() {
+		if scheduled[city] || !h.plan.Active(city, tickTime) || boost.Next(tickTime.Add(-h.tick)).After(tickTime) {
+			continue
+		}
+		if until := h.weatherService.BoostedUntil(city); tickTime.Before(until) {
+			log.Printf("Collecting %s on its boosted schedule until %s", city, until.Format(time.RFC3339))
+			due = append(due, city)
+		}
+	}
+	return due
+}
+
+// collectCity fetches one city's current weather and stores it when the observation changed
+func (h *Handler) collectCity(city string) *Response {
+	log.Printf("Processing weather data collection for city: %s", city)
+
+	// Fetch weather data from API
+	fetchResult, err := h.weatherService.FetchWeatherData(city)
+	if err != nil {
+		log.Printf("Error fetching weather data: %v", err)
+		return &Response{
+			StatusCode: errs.HTTPStatus(err),
+			Message:    fmt.Sprintf("Failed to fetch weather data: %v", err),
+		}
+	}
+
+	weatherResponse := fetchResult.Response
+	if !fetchResult.Changed {
+		if err := h.weatherService.RememberResponse(fetchResult); err != nil {
+			log.Printf("Error caching weather response: %v", err)
+		}
+		log.Printf("Observation for %s unchanged since last collection (dt=%d, notModified=%t), skipping storage",
+			weatherResponse.Name, weatherResponse.Dt, fetchResult.NotModified)
+		return &Response{
+			StatusCode: 200,
+			Message:    "Weather observation unchanged, nothing stored",
+			Data: map[string]interface{}{
+				"city":          weatherResponse.Name,
+				"observationDt": weatherResponse.Dt,
+			},
+		}
+	}
+
+	log.Printf("Successfully fetched weather data for %s: %.2f°C, %s",
+		weatherResponse.Name,
+		weatherResponse.Main.Temp,
+		weatherResponse.Weather[0].Description,
+	)
+
+	// Convert to internal models
+	weatherRecord := h.weatherService.ConvertToWeatherRecord(weatherResponse)
+	s3Data := h.weatherService.ConvertToS3Data(weatherResponse, weatherRecord)
+	previous := h.previousReading(weatherRecord.CityName)
+
+	// Store to DynamoDB
+	if err := h.records.StoreWeatherRecord(weatherRecord); err != nil {
+		log.Printf("Error storing to DynamoDB: %v", err)
+		return &Response{
+			StatusCode: errs.HTTPStatus(err),
+			Message:    fmt.Sprintf("Failed to store to DynamoDB: %v", err),
+		}
+	}
+	log.Printf("Successfully stored weather record to DynamoDB: %s", weatherRecord.ID)
+
+	// The snapshot only serves current conditions, so a failure doesn't fail the collection;
+	// the next newer reading replaces it
+	if h.dynamoDB != nil {
+		if _, err := h.dynamoDB.PutLatestWeather(context.Background(), weatherRecord); err != nil {
+			log.Printf("Error updating latest weather for %s: %v", weatherRecord.CityName, err)
+		}
+	}
+
+	// Store to S3
+	if err := h.s3Handler.StoreWeatherData(s3Data); err != nil {
+		log.Printf("Error storing to S3: %v", err)
+		return &Response{
+			StatusCode: errs.HTTPStatus(err),
+			Message:    fmt.Sprintf("Failed to store to S3: %v", err),
+		}
+	}
+	log.Printf("Successfully stored weather data to S3 for record: %s", weatherRecord.ID)
+
+	h.checkRapidChange(fetchResult, previous, weatherRecord)
+	if err := h.weatherService.RememberResponse(fetchResult); err != nil {
+		log.Printf("Error caching weather response: %v", err)
+	}
+
+	return &Response{
+		StatusCode: 200,
+		Message:    "Weather data processed successfully",
+		Data: map[string]interface{}{
+			"city":        weatherRecord.CityName,
+			"temperature": weatherRecord.Temperature,
+			"description": weatherRecord.Description,
+			"timestamp":   weatherRecord.Timestamp,
+			"recordId":    weatherRecord.ID,
+		},
+	}
+}
+
+// previousReading returns the city's latest stored reading, or nil when adaptive collection is off or there is none.
+// It reads the latest store, which orders readings by observation rather than returning an arbitrary record.
+func (h *Handler) previousReading(city string) *models.WeatherRecord {
+	if !h.adaptive.Enabled || h.plan == nil || h.latest == nil {
+		return nil
+	}
+	record, err := h.latest.GetLatestWeather(context.Background(), city)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Error reading previous record for %s: %v", city, err)
+		}
+		return nil
+	}
+	return record
+}
+
+// checkRapidChange boosts a city's sampling rate when the new reading changed fast since the
+// previous one. Each crossing extends the boost, which lapses once conditions settle.
+func (h *Handler) checkRapidChange(fetchResult *services.FetchResult, previous, current *models.WeatherRecord) {
+	if previous == nil {
+		return
+	}
+	changes := services.RapidChanges(previous, current, h.adaptive)
+	if len(changes) == 0 {
+		return
+	}
+
+	until := current.CreatedAt.Add(h.adaptive.BoostDuration)
+	fetchResult.Boost(until, strings.Join(changes, ", "))
+	metrics.Count(AdaptiveBoostsMetric, 1, map[string]string{"City": current.CityName})
+	log.Printf("Sampling %s every %s until %s: %s",
+		current.CityName, h.adaptive.BoostInterval, until.Format(time.RFC3339), strings.Join(changes, ", "))
+}
+
+// handleCompact compacts a day's archived readings into one Parquet file per city
+func (h *Handler) handleCompact(ctx context.Context, event WeatherEvent) *Response {
+	day, cities, err := h.maintenanceScope(event)
+	if err != nil {
+		return &Response{
+			StatusCode: 400,
+			Message:    err.Error(),
+		}
+	}
+
+	var results []*jobs.CompactionResult
+	for _, city := range cities {
+		result, err := h.compactor.CompactDay(ctx, city, day, event.DeleteOriginals)
+		if err != nil {
+			log.Printf("Error compacting %s for %s: %v", city, day.Format("2006-01-02"), err)
+			return &Response{
+				StatusCode: errs.HTTPStatus(err),
+				Message:    fmt.Sprintf("Failed to compact %s: %v", city, err),
+				Data:       results,
+			}
+		}
+		log.Printf("Compacted %d readings for %s on %s into %s (deleted %d originals)",
+			result.Rows, result.City, result.Date, result.Key, result.Deleted)
+		results = append(results, result)
+	}
+
+	return &Response{
+		StatusCode: 200,
+		Message:    "Weather data compacted successfully",
+		Data:       results,
+	}
+}
+
+// handleRollup summarizes the requested days for each city
+func (h *Handler) handleRollup(ctx context.Context, event WeatherEvent) *Response {
+	if h.rollup == nil {
+		return &Response{
+			StatusCode: 400,
+			Message:    "rollup requires a storage backend with a summary store",
+		}
+	}
+	day, cities, err := h.maintenanceScope(event)
+	if err != nil {
+		return &Response{
+			StatusCode: 400,
+			Message:    err.Error(),
+		}
+	}
+	days := event.Days
+	if days <= 0 {
+		days = 1
+	}
+
+	var summaries []*models.DailySummary
+	for _, city := range cities {
+		for i := days - 1; i >= 0; i-- {
+			date := day.AddDate(0, 0, -i)
+			summary, err := h.rollup.RollupDay(ctx, city, date)
+			if err != nil {
+				log.Printf("Error rolling up %s for %s: %v", city, date.Format("2006-01-02"), err)
+				return &Response{
+					StatusCode: errs.HTTPStatus(err),
+					Message:    fmt.Sprintf("Failed to roll up %s: %v", city, err),
+					Data:       summaries,
+				}
+			}
+			if summary == nil {
+				log.Printf("No records to roll up for %s on %s", city, date.Format("2006-01-02"))
+				continue
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return &Response{
+		StatusCode: 200,
+		Message:    fmt.Sprintf("Stored %d daily summaries", len(summaries)),
+		Data:       summaries,
+	}
+}
+
+// handleRecomputeTTL applies the current retention policy to the stored records of the
+// requested cities, or of every city when none are given
+func (h *Handler) handleRecomputeTTL(ctx context.Context, event WeatherEvent) *Response {
+	if h.dynamoDB == nil {
+		return &Response{
+			StatusCode: 400,
+			Message:    "recompute-ttl requires STORAGE_BACKEND=aws",
+		}
+	}
+
+	result, err := h.dynamoDB.RecomputeTTL(ctx, h.retention.ExpiresAt, handlers.TTLUpdateOptions{
+		Cities: event.Cities,
+		DryRun: event.DryRun,
+	})
+	if err != nil {
+		log.Printf("Error recomputing TTLs: %v", err)
+		return &Response{
+			StatusCode: errs.HTTPStatus(err),
+			Message:    fmt.Sprintf("Failed to recompute TTLs; rerun to continue: %v", err),
+			Data:       result,
+		}
+	}
+
+	log.Printf("Recomputed TTLs: %d records scanned, %d updated, %d unchanged, %d skipped (dry run: %t)",
+		result.Scanned, result.Updated, result.Unchanged, result.Skipped, event.DryRun)
+	return &Response{
+		StatusCode: 200,
+		Message:    "Record TTLs recomputed successfully",
+		Data:       result,
+	}
+}
+
+// maintenanceScope resolves the UTC day and cities targeted by a maintenance action
+func (h *Handler) maintenanceScope(event WeatherEvent) (time.Time, []string, error) {
+	day := time.Now().UTC().Add(-24 * time.Hour).Truncate(24 * time.Hour)
+	if event.Date != "" {
+		parsed, err := time.Parse("2006-01-02", event.Date)
+		if err != nil {
+			return time.Time{}, nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", event.Date)
+		}
+		day = parsed
+	}
+
+	cities := event.Cities
+	if len(cities) == 0 {
+		cities = []string{h.config.Weather.CityName}
+		if h.plan != nil {
+			cities = h.plan.Cities()
+		}
+	}
+
+	return day, cities, nil
+}
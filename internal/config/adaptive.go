@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// AdaptiveConfig holds settings for raising a city's sampling rate during fast-changing conditions
+type AdaptiveConfig struct {
+	// Enabled compares each new reading with the previous one; it needs city schedules
+	Enabled bool
+	// BoostInterval is how often a boosted city is collected
+	BoostInterval time.Duration
+	// BoostDuration is how long a boost lasts after the last threshold crossing
+	BoostDuration time.Duration
+	// MaxGap skips the comparison when the previous reading is older than this
+	MaxGap time.Duration
+	// Rates of change that trigger a boost; zero disables a check
+	PressureDropPerHour      float64
+	TemperatureChangePerHour float64
+	WindIncreasePerHour      float64
+	// PrecipitationOnset boosts when rain or snow starts
+	PrecipitationOnset bool
+}
+
+// LoadAdaptive loads the adaptive collection settings from the environment
+func LoadAdaptive() AdaptiveConfig {
+	return AdaptiveConfig{
+		Enabled:                  envBool("ADAPTIVE_COLLECTION", false),
+		BoostInterval:            time.Duration(envInt("ADAPTIVE_BOOST_INTERVAL_MINUTES", 10)) * time.Minute,
+		BoostDuration:            time.Duration(envInt("ADAPTIVE_BOOST_DURATION_MINUTES", 120)) * time.Minute,
+		MaxGap:                   time.Duration(envInt("ADAPTIVE_MAX_GAP_MINUTES", 360)) * time.Minute,
+		PressureDropPerHour:      envFloat("ADAPTIVE_PRESSURE_DROP_PER_HOUR", 2),
+		TemperatureChangePerHour: envFloat("ADAPTIVE_TEMPERATURE_CHANGE_PER_HOUR", 3),
+		WindIncreasePerHour:      envFloat("ADAPTIVE_WIND_INCREASE_PER_HOUR", 5),
+		PrecipitationOnset:       envBool("ADAPTIVE_PRECIPITATION_ONSET", true),
+	}
+}
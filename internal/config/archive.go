@@ -0,0 +1,27 @@
+package config
+
+// ArchiveConfig holds settings for the S3 weather data archive
+type ArchiveConfig struct {
+	// KeyLayout selects the S3 key scheme: legacy (weather-data/<yyyy>/<MM-dd>/) or hive
+	KeyLayout string
+	// KeyPrefix is the top-level prefix for archived weather data
+	KeyPrefix string
+	// Compression selects the encoding for new objects: none, gzip or zstd
+	Compression string
+	// CuratedPrefix is where compacted analytics files are written
+	CuratedPrefix string
+	// AllowDeleteOriginals lets compaction delete the JSON objects it compacted. The archive
+	// readers don't read curated Parquet files, so those days leave history, rehydrate and reprocess.
+	AllowDeleteOriginals bool
+}
+
+// LoadArchive loads the S3 archive settings from the environment
+func LoadArchive() ArchiveConfig {
+	return ArchiveConfig{
+		KeyLayout:            envString("S3_KEY_LAYOUT", "legacy"),
+		KeyPrefix:            envString("S3_KEY_PREFIX", "weather-data"),
+		Compression:          envString("S3_COMPRESSION", "none"),
+		CuratedPrefix:        envString("S3_CURATED_PREFIX", "curated/weather-daily"),
+		AllowDeleteOriginals: envBool("COMPACT_ALLOW_DELETE_ORIGINALS", false),
+	}
+}
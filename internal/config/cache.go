@@ -0,0 +1,27 @@
+package config
+
+import "strings"
+
+// Provider cache backends
+const (
+	ProviderCacheMemory   = "memory"
+	ProviderCacheS3       = "s3"
+	ProviderCacheDynamoDB = "dynamodb"
+)
+
+// ProviderCacheConfig holds settings for caching weather provider responses
+type ProviderCacheConfig struct {
+	// Backend persists cache entries beyond the warm Lambda container.
+	// The in-memory layer is always used in front of it.
+	Backend string
+	// Prefix is the S3 key prefix used by the s3 backend
+	Prefix string
+}
+
+// LoadProviderCache loads the provider cache settings from the environment
+func LoadProviderCache() ProviderCacheConfig {
+	return ProviderCacheConfig{
+		Backend: strings.ToLower(envString("PROVIDER_CACHE_BACKEND", ProviderCacheMemory)),
+		Prefix:  envString("PROVIDER_CACHE_PREFIX", "provider-cache"),
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// CollectionConfig holds settings for per-city collection schedules
+type CollectionConfig struct {
+	// CitySchedules is a JSON array of city schedules; empty collects CITY_NAME on every tick
+	CitySchedules string
+	// CitySchedulesFile is read instead of CitySchedules when set
+	CitySchedulesFile string
+	// Tick is the interval of the EventBridge rule (or server schedule) invoking the collector;
+	// each tick collects the cities with a scheduled time since the previous one
+	Tick time.Duration
+}
+
+// LoadCollection loads the collection schedule settings from the environment
+func LoadCollection() CollectionConfig {
+	return CollectionConfig{
+		CitySchedules:     envString("CITY_SCHEDULES", ""),
+		CitySchedulesFile: envString("CITY_SCHEDULES_FILE", ""),
+		Tick:              time.Duration(envInt("COLLECT_TICK_MINUTES", 60)) * time.Minute,
+	}
+}
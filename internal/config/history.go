@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// HistoryConfig holds settings for the weather history API
+type HistoryConfig struct {
+	// ArchiveFallback serves ranges older than a city's record retention from the S3 archive
+	ArchiveFallback bool
+	// DailySummaries serves days older than a city's record retention from daily summaries
+	DailySummaries bool
+	// Retention is how long records stay queryable in DynamoDB before TTL expiry
+	Retention RetentionConfig
+	// MaxPeriod caps the time range a single request may ask for
+	MaxPeriod time.Duration
+	// ArchiveConcurrency bounds concurrent S3 reads for archive queries
+	ArchiveConcurrency int
+}
+
+// LoadHistory loads the history API settings from the environment
+func LoadHistory() HistoryConfig {
+	return HistoryConfig{
+		ArchiveFallback:    envBool("HISTORY_ARCHIVE_FALLBACK", true),
+		DailySummaries:     envBool("HISTORY_DAILY_SUMMARIES", true),
+		Retention:          LoadRetention(),
+		MaxPeriod:          time.Duration(envInt("HISTORY_MAX_PERIOD_HOURS", 2160)) * time.Hour,
+		ArchiveConcurrency: envInt("HISTORY_ARCHIVE_CONCURRENCY", 16),
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionConfig decides how long weather records are kept before TTL expiry.
+// A city override wins over an environment override, which wins over Default.
+// A retention of zero keeps records forever.
+type RetentionConfig struct {
+	// Environment is the deployment environment the environment overrides are matched against
+	Environment string
+	Default     time.Duration
+	// Environments maps an ENVIRONMENT name such as dev or prod to its retention
+	Environments map[string]time.Duration
+	// Cities maps a city name to its retention
+	Cities map[string]time.Duration
+}
+
+// LoadRetention loads the retention policy from the environment. Overrides are comma-separated
+// name=days pairs, such as RECORD_RETENTION_CITY_DAYS="Tokyo=365,London=0".
+func LoadRetention() RetentionConfig {
+	return RetentionConfig{
+		Environment:  envString("ENVIRONMENT", ""),
+		Default:      days(envInt("RECORD_RETENTION_DAYS", 30)),
+		Environments: envDays("RECORD_RETENTION_ENVIRONMENT_DAYS"),
+		Cities:       envDays("RECORD_RETENTION_CITY_DAYS"),
+	}
+}
+
+// For returns the retention for a city's records; zero means keep forever
+func (r RetentionConfig) For(city string) time.Duration {
+	if retention, ok := r.Cities[city]; ok {
+		return retention
+	}
+	if retention, ok := r.Environments[r.Environment]; ok {
+		return retention
+	}
+	return r.Default
+}
+
+// ExpiresAt returns the TTL in Unix seconds for a record of city collected at collected,
+// or 0 when the record is kept forever
+func (r RetentionConfig) ExpiresAt(city string, collected time.Time) int64 {
+	retention := r.For(city)
+	if retention <= 0 {
+		return 0
+	}
+	return collected.Add(retention).Unix()
+}
+
+func days(n int) time.Duration {
+	if n < 0 {
+		n = 0
+	}
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// envDays parses name=days pairs, skipping invalid entries
+func envDays(key string) map[string]time.Duration {
+	overrides := make(map[string]time.Duration)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || strings.TrimSpace(name) == "" || err != nil || n < 0 {
+			log.Printf("Ignoring invalid %s entry %q", key, pair)
+			continue
+		}
+		overrides[strings.TrimSpace(name)] = days(n)
+	}
+	return overrides
+}
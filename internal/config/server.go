@@ -0,0 +1,34 @@
+package config
+
+import "time"
+
+// ScheduleOff disables a self-hosted server schedule
+const ScheduleOff = "off"
+
+// ServerConfig holds settings for the self-hosted weather server
+type ServerConfig struct {
+	// Addr is the HTTP listen address
+	Addr string
+	// APIKey, when set, must be sent in the X-Api-Key header, like the API Gateway usage plan
+	APIKey string
+	// CollectSchedule is the cron expression for weather collection
+	CollectSchedule string
+	// CompactSchedule is the cron expression for daily compaction, or off
+	CompactSchedule string
+	// RollupSchedule is the cron expression for the daily summary rollup, or off
+	RollupSchedule string
+	// ShutdownTimeout bounds how long shutdown waits for requests and scheduled jobs
+	ShutdownTimeout time.Duration
+}
+
+// LoadServer loads the self-hosted server settings from the environment
+func LoadServer() ServerConfig {
+	return ServerConfig{
+		Addr:            envString("SERVER_ADDR", ":8080"),
+		APIKey:          envString("SERVER_API_KEY", ""),
+		CollectSchedule: envString("COLLECT_SCHEDULE", "@hourly"),
+		CompactSchedule: envString("COMPACT_SCHEDULE", "30 1 * * *"),
+		RollupSchedule:  envString("ROLLUP_SCHEDULE", "15 0 * * *"),
+		ShutdownTimeout: time.Duration(envInt("SERVER_SHUTDOWN_TIMEOUT_SECONDS", 30)) * time.Second,
+	}
+}
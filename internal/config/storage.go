@@ -0,0 +1,38 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage backends
+const (
+	StorageAWS    = "aws"
+	StorageMemory = "memory"
+	StorageLocal  = "local"
+	StorageSQLite = "sqlite"
+)
+
+// StorageConfig selects where records and archived weather data are kept
+type StorageConfig struct {
+	// Backend is aws (DynamoDB and S3), memory, local or sqlite
+	Backend string
+	// Dir is the root directory of the local and sqlite backends
+	Dir string
+	// SQLitePath is the database file of the sqlite backend
+	SQLitePath string
+	// PurgeInterval is how often the sqlite backend deletes expired records
+	PurgeInterval time.Duration
+}
+
+// LoadStorage loads the storage backend settings from the environment
+func LoadStorage() StorageConfig {
+	dir := envString("STORAGE_DIR", "./data")
+	return StorageConfig{
+		Backend:       strings.ToLower(envString("STORAGE_BACKEND", StorageAWS)),
+		Dir:           dir,
+		SQLitePath:    envString("SQLITE_PATH", filepath.Join(dir, "weather.db")),
+		PurgeInterval: time.Duration(envInt("SQLITE_PURGE_INTERVAL_MINUTES", 60)) * time.Minute,
+	}
+}
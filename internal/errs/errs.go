@@ -0,0 +1,121 @@
+// Package errs defines the error taxonomy shared by the stores, services and APIs. Errors are
+// classified by wrapping one of the sentinels, so callers test the class with errors.Is while
+// errors.As still reaches the underlying SDK or HTTP error.
+package errs
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error classes
+var (
+	// ErrNotFound means the requested record, object or reading does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrThrottled means a backing service rejected the request for exceeding its capacity
+	ErrThrottled = errors.New("throttled")
+	// ErrValidation means the caller's input is invalid; retrying it unchanged won't help
+	ErrValidation = errors.New("validation failed")
+	// ErrUpstreamUnavailable means the weather provider or an AWS service failed or couldn't be reached
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	// ErrConflict means a conditional write lost to a concurrent update
+	ErrConflict = errors.New("conflict")
+)
+
+// Machine-readable codes for each class. They are part of the API contract and must not change.
+const (
+	CodeNotFound            = "not_found"
+	CodeThrottled           = "throttled"
+	CodeValidation          = "validation_failed"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeConflict            = "conflict"
+	CodeInternal            = "internal"
+)
+
+// Mark classifies err as kind without changing its message. A nil err stays nil.
+func Mark(err, kind error) error {
+	if err == nil {
+		return nil
+	}
+	return &marked{err: err, kind: kind}
+}
+
+type marked struct {
+	err, kind error
+}
+
+func (m *marked) Error() string   { return m.err.Error() }
+func (m *marked) Unwrap() []error { return []error{m.err, m.kind} }
+
+// ValidationError reports an invalid input; it matches ErrValidation
+type ValidationError struct {
+	// Field names the offending parameter
+	Field   string
+	Message string
+}
+
+// Invalid creates a ValidationError for field
+func Invalid(field, message string) *ValidationError {
+	return &ValidationError{Field: field, Message: message}
+}
+
+func (e *ValidationError) Error() string        { return e.Message }
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+// UpstreamError reports a failed call to an external service. It matches ErrThrottled for
+// 429 responses, ErrNotFound for 404, and ErrUpstreamUnavailable for 5xx responses and
+// transport failures (StatusCode 0).
+type UpstreamError struct {
+	Service    string
+	StatusCode int
+	Err        error
+}
+
+func (e *UpstreamError) Error() string { return e.Err.Error() }
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+func (e *UpstreamError) Is(target error) bool {
+	switch target {
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUpstreamUnavailable:
+		return e.StatusCode == 0 || e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// Code returns the machine-readable code of err's class, or CodeInternal when it has none
+func Code(err error) string {
+	switch {
+	case errors.Is(err, ErrValidation):
+		return CodeValidation
+	case errors.Is(err, ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, ErrConflict):
+		return CodeConflict
+	case errors.Is(err, ErrThrottled):
+		return CodeThrottled
+	case errors.Is(err, ErrUpstreamUnavailable):
+		return CodeUpstreamUnavailable
+	}
+	return CodeInternal
+}
+
+// HTTPStatus returns the HTTP status code for err's class
+func HTTPStatus(err error) int {
+	switch Code(err) {
+	case CodeValidation:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeThrottled:
+		return http.StatusTooManyRequests
+	case CodeUpstreamUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
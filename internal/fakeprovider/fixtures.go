@@ -0,0 +1,90 @@
+package fakeprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var fixtureNamePattern = regexp.MustCompile(`[^a-z0-9\-]+`)
+
+// FixtureStore reads and writes golden provider responses, one JSON file per city
+type FixtureStore struct {
+	dir string
+}
+
+// NewFixtureStore creates a fixture store rooted at dir
+func NewFixtureStore(dir string) *FixtureStore {
+	return &FixtureStore{dir: dir}
+}
+
+// Path returns the fixture file path for a city
+func (f *FixtureStore) Path(city string) string {
+	name := fixtureNamePattern.ReplaceAllString(strings.ToLower(strings.TrimSpace(city)), "-")
+	return filepath.Join(f.dir, name+".json")
+}
+
+// Load reads the fixture for a city
+func (f *FixtureStore) Load(city string) ([]byte, error) {
+	body, err := os.ReadFile(f.Path(city))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture for %s: %w", city, err)
+	}
+	return body, nil
+}
+
+// Save writes an indented fixture for a city
+func (f *FixtureStore) Save(city string, body []byte) error {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return fmt.Errorf("refusing to record invalid JSON for %s: %w", city, err)
+	}
+	indented.WriteByte('\n')
+
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(f.Path(city), indented.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture for %s: %w", city, err)
+	}
+	return nil
+}
+
+// record proxies a request to the real provider and saves successful responses as fixtures
+func (s *Server) record(w http.ResponseWriter, r *http.Request, city string) {
+	upstreamURL, err := url.Parse(s.upstream)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "invalid upstream URL")
+		return
+	}
+	upstreamURL.RawQuery = r.URL.RawQuery
+
+	resp, err := http.Get(upstreamURL.String())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		if err := s.fixtures.Save(city, body); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	writeJSON(w, r, resp.StatusCode, body)
+}
@@ -0,0 +1,307 @@
+// Package fakeprovider implements an OpenWeatherMap-compatible HTTP server for
+// offline development and tests.
+package fakeprovider
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weather-lambda/internal/models"
+)
+
+// Modes supported by the fake provider
+const (
+	ModeDeterministic = "deterministic"
+	ModeReplay        = "replay"
+	ModeRecord        = "record"
+)
+
+// ObservationInterval is how often the generated observation time (dt) advances
+const ObservationInterval = 10 * time.Minute
+
+var countries = []string{"JP", "US", "GB", "FR", "AU"}
+
+// Step is one scripted response. Steps are served in order before falling back to the mode's default.
+type Step struct {
+	Status    int               `json:"status,omitempty"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	LatencyMS int               `json:"latencyMs,omitempty"`
+	Malformed bool              `json:"malformed,omitempty"`
+	Repeat    int               `json:"repeat,omitempty"`
+}
+
+// Server is a fake weather provider implementing http.Handler
+type Server struct {
+	mode     string
+	fixtures *FixtureStore
+	upstream string
+	latency  time.Duration
+	clock    func() time.Time
+
+	mu       sync.Mutex
+	script   []Step
+	requests int
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithScript queues scripted responses served before the default behavior
+func WithScript(steps ...Step) Option {
+	return func(s *Server) {
+		s.script = append(s.script, steps...)
+	}
+}
+
+// WithLatency delays every response
+func WithLatency(latency time.Duration) Option {
+	return func(s *Server) {
+		s.latency = latency
+	}
+}
+
+// WithClock overrides the clock used to generate observation times
+func WithClock(clock func() time.Time) Option {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithFixtures sets the fixture directory used by the replay and record modes
+func WithFixtures(dir string) Option {
+	return func(s *Server) {
+		s.fixtures = NewFixtureStore(dir)
+	}
+}
+
+// WithUpstream sets the real provider URL proxied by the record mode
+func WithUpstream(upstreamURL string) Option {
+	return func(s *Server) {
+		s.upstream = upstreamURL
+	}
+}
+
+// NewServer creates a new fake provider in the given mode
+func NewServer(mode string, opts ...Option) (*Server, error) {
+	s := &Server{
+		mode:  mode,
+		clock: time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	switch mode {
+	case ModeDeterministic:
+	case ModeReplay:
+		if s.fixtures == nil {
+			return nil, fmt.Errorf("replay mode requires a fixture directory")
+		}
+	case ModeRecord:
+		if s.fixtures == nil || s.upstream == "" {
+			return nil, fmt.Errorf("record mode requires a fixture directory and an upstream URL")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported fake provider mode: %s", mode)
+	}
+
+	return s, nil
+}
+
+// Enqueue appends scripted responses
+func (s *Server) Enqueue(steps ...Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, steps...)
+}
+
+// Requests returns the number of requests served so far
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	step, scripted := s.nextStep()
+
+	delay := s.latency
+	if scripted && step.LatencyMS > 0 {
+		delay = time.Duration(step.LatencyMS) * time.Millisecond
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if scripted {
+		s.serveStep(w, r, step)
+		return
+	}
+
+	city := strings.TrimSpace(r.URL.Query().Get("q"))
+	if city == "" {
+		writeError(w, http.StatusBadRequest, "Nothing to geocode")
+		return
+	}
+
+	switch s.mode {
+	case ModeReplay:
+		body, err := s.fixtures.Load(city)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "city not found")
+			return
+		}
+		writeJSON(w, r, http.StatusOK, body)
+	case ModeRecord:
+		s.record(w, r, city)
+	default:
+		body, err := json.Marshal(GenerateResponse(city, s.clock()))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, r, http.StatusOK, body)
+	}
+}
+
+// nextStep pops the next scripted response, if any
+func (s *Server) nextStep() (Step, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if len(s.script) == 0 {
+		return Step{}, false
+	}
+
+	step := s.script[0]
+	if step.Repeat > 1 {
+		s.script[0].Repeat--
+	} else {
+		s.script = s.script[1:]
+	}
+
+	return step, true
+}
+
+func (s *Server) serveStep(w http.ResponseWriter, r *http.Request, step Step) {
+	for name, value := range step.Headers {
+		w.Header().Set(name, value)
+	}
+
+	status := step.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	switch {
+	case step.Malformed:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"name": "truncated", "main": {"temp": `)
+	case len(step.Body) > 0:
+		writeJSON(w, r, status, step.Body)
+	case status == http.StatusOK:
+		body, _ := json.Marshal(GenerateResponse(r.URL.Query().Get("q"), s.clock()))
+		writeJSON(w, r, status, body)
+	default:
+		writeError(w, status, http.StatusText(status))
+	}
+}
+
+// GenerateResponse builds a deterministic response for a city. Values depend only on
+// the city name and the observation window containing now.
+func GenerateResponse(city string, now time.Time) *models.WeatherResponse {
+	sum := sha1.Sum([]byte(strings.ToLower(city)))
+	seed := binary.BigEndian.Uint64(sum[:8])
+	dt := now.UTC().Truncate(ObservationInterval)
+	// Vary readings smoothly through the day so consecutive observations differ
+	slot := float64(dt.Hour()*60+dt.Minute()) / float64(24*60)
+
+	conditions := []models.Weather{
+		{ID: 800, Main: "Clear", Description: "clear sky", Icon: "01d"},
+		{ID: 802, Main: "Clouds", Description: "scattered clouds", Icon: "03d"},
+		{ID: 500, Main: "Rain", Description: "light rain", Icon: "10d"},
+	}
+
+	temp := float64(seed%300)/10 + 4*slot
+	condition := conditions[int((seed>>32)%uint64(len(conditions)))]
+	var rain *models.Precipitation
+	if condition.Main == "Rain" {
+		rain = &models.Precipitation{OneHour: 0.2 + 2*slot}
+	}
+	return &models.WeatherResponse{
+		Name: city,
+		Coord: models.Coord{
+			Lon: float64(seed%36000)/100 - 180,
+			Lat: float64((seed>>16)%18000)/100 - 90,
+		},
+		Main: models.Main{
+			Temp:      temp,
+			FeelsLike: temp - 1,
+			TempMin:   temp - 2,
+			TempMax:   temp + 2,
+			Pressure:  990 + int((seed>>8)%40),
+			Humidity:  30 + int((seed>>24)%60),
+		},
+		Weather: []models.Weather{condition},
+		Wind: models.Wind{
+			Speed: float64((seed>>40)%150) / 10,
+			Deg:   int((seed >> 48) % 360),
+		},
+		Clouds: models.Clouds{All: int((seed >> 56) % 100)},
+		Sys: models.Sys{
+			Country: countries[int(sum[8])%len(countries)],
+			Sunrise: dt.Truncate(24 * time.Hour).Add(5 * time.Hour).Unix(),
+			Sunset:  dt.Truncate(24 * time.Hour).Add(18 * time.Hour).Unix(),
+		},
+		Dt:   dt.Unix(),
+		Rain: rain,
+	}
+}
+
+// writeJSON writes a JSON body with an ETag, answering matching conditional requests with 304
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	sum := sha1.Sum(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if status == http.StatusOK {
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeError writes an error body shaped like OpenWeatherMap's
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"cod":     fmt.Sprintf("%d", status),
+		"message": message,
+	})
+}
@@ -2,21 +2,22 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/weather-lambda/internal/config"
 	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
 )
 
 // DynamoDBHandler handles DynamoDB operations
 type DynamoDBHandler struct {
-	client    *dynamodb.DynamoDB
+	client    DynamoDBClient
 	tableName string
 }
 
@@ -36,18 +37,23 @@ func NewDynamoDBHandler(cfg *config.Config, sess ...*session.Session) (*DynamoDB
 		}
 	}
 
+	return NewDynamoDBHandlerFromClient(dynamodb.New(sess_client), cfg.AWS.DynamoDBTable), nil
+}
+
+// NewDynamoDBHandlerFromClient creates a handler over any DynamoDB client, such as a fake in tests
+func NewDynamoDBHandlerFromClient(client DynamoDBClient, tableName string) *DynamoDBHandler {
 	return &DynamoDBHandler{
-		client:    dynamodb.New(sess_client),
-		tableName: cfg.AWS.DynamoDBTable,
-	}, nil
+		client:    client,
+		tableName: tableName,
+	}
 }
 
 // StoreWeatherRecord stores a weather record to DynamoDB
 func (h *DynamoDBHandler) StoreWeatherRecord(record *models.WeatherRecord) error {
 	// Convert the record to DynamoDB attribute value map
-	av, err := dynamodbattribute.MarshalMap(record)
+	av, err := marshalWeatherRecord(record)
 	if err != nil {
-		return fmt.Errorf("failed to marshal weather record: %w", err)
+		return err
 	}
 
 	// Put the item into DynamoDB
@@ -56,12 +62,36 @@ func (h *DynamoDBHandler) StoreWeatherRecord(record *models.WeatherRecord) error
 		Item:      av,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to put item to DynamoDB: %w", err)
+		return fmt.Errorf("failed to put item to DynamoDB: %w", awsError(err))
 	}
 
 	return nil
 }
 
+// ReplaceWeatherRecord overwrites a weather record only if it still exists, so expired
+// records are not resurrected. It reports whether the record was replaced.
+func (h *DynamoDBHandler) ReplaceWeatherRecord(record *models.WeatherRecord) (bool, error) {
+	av, err := marshalWeatherRecord(record)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = h.client.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(h.tableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to replace item in DynamoDB: %w", awsError(err))
+	}
+
+	return true, nil
+}
+
 // GetWeatherRecord retrieves a weather record from DynamoDB
 func (h *DynamoDBHandler) GetWeatherRecord(id, timestamp string) (*models.WeatherRecord, error) {
 	result, err := h.client.GetItem(&dynamodb.GetItemInput{
@@ -76,142 +106,74 @@ func (h *DynamoDBHandler) GetWeatherRecord(id, timestamp string) (*models.Weathe
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get item from DynamoDB: %w", err)
+		return nil, fmt.Errorf("failed to get item from DynamoDB: %w", awsError(err))
 	}
 
 	if result.Item == nil {
-		return nil, fmt.Errorf("weather record not found")
+		return nil, fmt.Errorf("weather record not found: %w", storage.ErrNotFound)
 	}
 
-	var record models.WeatherRecord
-	err = dynamodbattribute.UnmarshalMap(result.Item, &record)
+	record, err := decodeWeatherRecord(result.Item)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal weather record: %w", err)
 	}
 
-	return &record, nil
+	return record, nil
 }
 
-// QueryWeatherRecordsByCity queries weather records by city name
+// QueryWeatherRecordsByCity returns up to limit of a city's unexpired records, most recent first
 func (h *DynamoDBHandler) QueryWeatherRecordsByCity(cityName string, limit int64) ([]*models.WeatherRecord, error) {
-	// This would require a GSI (Global Secondary Index) on cityName
-	// For now, we'll use scan with filter (not recommended for production)
-	input := &dynamodb.ScanInput{
-		TableName:        aws.String(h.tableName),
-		FilterExpression: aws.String("cityName = :city"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":city": {
-				S: aws.String(cityName),
-			},
-		},
-	}
-
-	if limit > 0 {
-		input.Limit = aws.Int64(limit)
-	}
-
-	result, err := h.client.Scan(input)
+	page, err := h.QueryWeatherRecordsByCityPage(context.Background(), cityName, limit, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan DynamoDB table: %w", err)
-	}
-
-	var records []*models.WeatherRecord
-	for _, item := range result.Items {
-		var record models.WeatherRecord
-		err := dynamodbattribute.UnmarshalMap(item, &record)
-		if err != nil {
-			continue // Skip invalid records
-		}
-		records = append(records, &record)
+		return nil, err
 	}
-
-	return records, nil
+	return page.Records, nil
 }
 
-// QueryRecentWeatherRecords queries recent weather records
+// QueryRecentWeatherRecords returns up to limit of the unexpired records stored under id, most recent first
 func (h *DynamoDBHandler) QueryRecentWeatherRecords(id string, limit int64) ([]*models.WeatherRecord, error) {
-	input := &dynamodb.QueryInput{
-		TableName:              aws.String(h.tableName),
-		KeyConditionExpression: aws.String("id = :id"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":id": {
-				S: aws.String(id),
-			},
-		},
-		ScanIndexForward: aws.Bool(false), // Sort by timestamp descending (most recent first)
-	}
-
-	if limit > 0 {
-		input.Limit = aws.Int64(limit)
-	}
-
-	result, err := h.client.Query(input)
+	page, err := h.QueryRecentWeatherRecordsPage(context.Background(), id, limit, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to query DynamoDB table: %w", err)
+		return nil, err
 	}
-
-	var records []*models.WeatherRecord
-	for _, item := range result.Items {
-		var record models.WeatherRecord
-		err := dynamodbattribute.UnmarshalMap(item, &record)
-		if err != nil {
-			continue // Skip invalid records
-		}
-		records = append(records, &record)
-	}
-
-	return records, nil
+	return page.Records, nil
 }
 
-// GetWeatherHistory retrieves weather records for a specific city within a time range
+// GetWeatherHistory retrieves weather records for a specific city within a time range, oldest first
 func (h *DynamoDBHandler) GetWeatherHistory(ctx context.Context, cityName string, startTime, endTime time.Time) ([]models.WeatherRecord, error) {
-	// Since we're using city-timestamp as the ID structure, we need to scan with filters
-	// In production, consider adding a GSI with cityName as partition key and timestamp as sort key
-	
-	startTimeStr := startTime.Format(time.RFC3339)
-	endTimeStr := endTime.Format(time.RFC3339)
-	
-	input := &dynamodb.ScanInput{
-		TableName:        aws.String(h.tableName),
-		FilterExpression: aws.String("cityName = :city AND #ts BETWEEN :start AND :end"),
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(h.tableName),
+		IndexName:              aws.String(CityTimeIndex),
+		KeyConditionExpression: aws.String("cityName = :city AND #observedAt BETWEEN :start AND :end"),
 		ExpressionAttributeNames: map[string]*string{
-			"#ts": aws.String("timestamp"),
+			"#observedAt": aws.String(observedAtAttribute),
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":city": {
 				S: aws.String(cityName),
 			},
-			":start": {
-				S: aws.String(startTimeStr),
-			},
-			":end": {
-				S: aws.String(endTimeStr),
-			},
+			":start": unixAttribute(startTime),
+			":end":   unixAttribute(endTime),
 		},
+		ScanIndexForward: aws.Bool(true), // Sort by observation time ascending (oldest first)
 	}
 
 	var records []models.WeatherRecord
-	err := h.client.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+	err := h.client.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
 		for _, item := range page.Items {
-			var record models.WeatherRecord
-			err := dynamodbattribute.UnmarshalMap(item, &record)
+			record, err := decodeWeatherRecord(item)
 			if err != nil {
-				continue // Skip invalid records
+				continue // Skip records that can't be migrated; counted by decodeWeatherRecord
 			}
-			records = append(records, record)
+			records = append(records, *record)
 		}
 		return !lastPage
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan weather history: %w", err)
+		return nil, fmt.Errorf("failed to query weather history: %w", awsError(err))
 	}
 
-	// Sort records by timestamp (oldest first)
-	sort.Slice(records, func(i, j int) bool {
-		return records[i].Timestamp < records[j].Timestamp
-	})
-
 	return records, nil
 }
 
@@ -244,19 +206,18 @@ func (h *DynamoDBHandler) GetWeatherHistoryByCityID(ctx context.Context, cityID
 	var records []models.WeatherRecord
 	err := h.client.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
 		for _, item := range page.Items {
-			var record models.WeatherRecord
-			err := dynamodbattribute.UnmarshalMap(item, &record)
+			record, err := decodeWeatherRecord(item)
 			if err != nil {
-				continue // Skip invalid records
+				continue // Skip records that can't be migrated; counted by decodeWeatherRecord
 			}
-			records = append(records, record)
+			records = append(records, *record)
 		}
 		return !lastPage
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to query weather history: %w", err)
+		return nil, fmt.Errorf("failed to query weather history: %w", awsError(err))
 	}
 
 	return records, nil
-}
\ No newline at end of file
+}
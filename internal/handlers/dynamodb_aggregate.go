@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/models"
+)
+
+// Aggregate items share the records table, keyed apart from weather records. Like summaries
+// they carry no cityName or ttl, so they stay out of the city index and never expire.
+const aggregateIDPrefix = "aggregate#"
+
+// maxAggregateAttempts bounds retries when concurrent writers update the same aggregate
+const maxAggregateAttempts = 5
+
+// aggregateReplayWindow is how long after collection a record's stream changes may still be
+// redelivered: the stream keeps them for 24 hours, and records can be written a while after
+// they were collected. Applied entries for older records are pruned to keep aggregates small.
+const aggregateReplayWindow = 48 * time.Hour
+
+// AggregateChange is one record's contribution to an aggregate
+type AggregateChange struct {
+	// Key identifies the source record across redeliveries, as id@timestamp with the record's
+	// RFC 3339 collection timestamp
+	Key    string
+	Values models.AggregateValues
+	// Previous holds the record's values before a modification; nil for new records
+	Previous *models.AggregateValues
+	// Removed backs Previous out of an aggregate the modified record has moved out of
+	Removed bool
+}
+
+// removedFingerprint marks a record backed out of an aggregate, so redelivering its removal
+// is skipped
+const removedFingerprint = "-"
+
+// ApplyAggregateChanges folds changes into the city's aggregate for a period starting at
+// start, creating it when missing. Changes already counted with the same values are skipped,
+// so redelivered records are harmless; a record counted with other values has them replaced,
+// and one modified into another aggregate has them backed out.
+// Records collected before the replay window are forgotten, and a modification of one is
+// taken to replace values counted before its entry was pruned. Concurrent writers are
+// resolved with a version check and retried.
+func (h *DynamoDBHandler) ApplyAggregateChanges(ctx context.Context, city, period, start string, changes []AggregateChange) error {
+	for attempt := 0; attempt < maxAggregateAttempts; attempt++ {
+		aggregate, err := h.getAggregate(ctx, city, period, start)
+		if err != nil {
+			return err
+		}
+
+		horizon := time.Now().Add(-aggregateReplayWindow)
+		changed := false
+		for _, change := range changes {
+			counted, ok := aggregate.Applied[change.Key]
+			if change.Removed {
+				if ok && counted != aggregateFingerprint(*change.Previous) || !ok && !appliedBefore(change.Key, horizon) {
+					continue // Already backed out, replaced since, or never counted here
+				}
+				aggregate.Remove(*change.Previous)
+				aggregate.Applied[change.Key] = removedFingerprint
+				changed = true
+				continue
+			}
+
+			fingerprint := aggregateFingerprint(change.Values)
+			switch {
+			case ok && counted == fingerprint:
+				continue
+			case ok && counted == removedFingerprint:
+				// The record moves back into an aggregate it was backed out of
+			case ok:
+				if change.Previous == nil || aggregateFingerprint(*change.Previous) != counted {
+					// The counted values are neither these nor the ones being replaced, so this
+					// change is older than one already applied
+					log.Printf("Skipping out-of-order change to %s in %s aggregate %s/%s", change.Key, period, city, start)
+					continue
+				}
+				aggregate.Remove(*change.Previous)
+			case change.Previous != nil && appliedBefore(change.Key, horizon):
+				aggregate.Remove(*change.Previous)
+			}
+			aggregate.Add(change.Values)
+			aggregate.Applied[change.Key] = fingerprint
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		for key := range aggregate.Applied {
+			if appliedBefore(key, horizon) {
+				delete(aggregate.Applied, key)
+			}
+		}
+
+		err = h.putAggregate(ctx, aggregate)
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			continue // Another writer updated the aggregate; reapply on its version
+		}
+		return err
+	}
+	return fmt.Errorf("failed to update %s aggregate %s/%s: too many concurrent updates: %w", period, city, start, errs.ErrConflict)
+}
+
+// GetAggregates returns a city's aggregates for a period between the start keys first and
+// last inclusive, oldest first
+func (h *DynamoDBHandler) GetAggregates(ctx context.Context, city, period, first, last string) ([]models.Aggregate, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(h.tableName),
+		KeyConditionExpression: aws.String("id = :id AND #ts BETWEEN :first AND :last"),
+		ProjectionExpression:   aws.String("#city, #period, #ts, #count, temperature, humidity, pressure, windSpeed, precipitation, updatedAt"),
+		ExpressionAttributeNames: map[string]*string{
+			"#city":   aws.String("city"),
+			"#period": aws.String("period"),
+			"#ts":     aws.String("timestamp"),
+			"#count":  aws.String("count"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id":    {S: aws.String(aggregateID(city, period))},
+			":first": {S: aws.String(first)},
+			":last":  {S: aws.String(last)},
+		},
+	}
+
+	var aggregates []models.Aggregate
+	var decodeErr error
+	err := h.client.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var decoded []models.Aggregate
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &decoded); decodeErr != nil {
+			return false
+		}
+		aggregates = append(aggregates, decoded...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query aggregates: %w", awsError(err))
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode aggregates: %w", decodeErr)
+	}
+	return aggregates, nil
+}
+
+// getAggregate reads an aggregate with a consistent read, returning an empty one when missing
+func (h *DynamoDBHandler) getAggregate(ctx context.Context, city, period, start string) (*models.Aggregate, error) {
+	result, err := h.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(h.tableName),
+		Key:            aggregateKey(city, period, start),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s aggregate %s/%s: %w", period, city, start, awsError(err))
+	}
+
+	aggregate := &models.Aggregate{City: city, Period: period, Start: start}
+	if len(result.Item) > 0 {
+		if err := dynamodbattribute.UnmarshalMap(result.Item, aggregate); err != nil {
+			return nil, fmt.Errorf("failed to decode %s aggregate %s/%s: %w", period, city, start, err)
+		}
+	}
+	if aggregate.Applied == nil {
+		aggregate.Applied = make(map[string]string)
+	}
+	return aggregate, nil
+}
+
+// putAggregate writes the aggregate if nobody else has since it was read
+func (h *DynamoDBHandler) putAggregate(ctx context.Context, aggregate *models.Aggregate) error {
+	expected := aggregate.Version
+	aggregate.Version++
+	aggregate.UpdatedAt = time.Now().UTC()
+
+	item, err := dynamodbattribute.MarshalMap(aggregate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal aggregate: %w", err)
+	}
+	item["id"] = &dynamodb.AttributeValue{S: aws.String(aggregateID(aggregate.City, aggregate.Period))}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(h.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+	if expected > 0 {
+		input.ConditionExpression = aws.String("#version = :version")
+		input.ExpressionAttributeNames = map[string]*string{"#version": aws.String("version")}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.FormatInt(expected, 10))},
+		}
+	}
+
+	if _, err := h.client.PutItemWithContext(ctx, input); err != nil {
+		return fmt.Errorf("failed to put %s aggregate %s/%s: %w", aggregate.Period, aggregate.City, aggregate.Start, awsError(err))
+	}
+	return nil
+}
+
+func aggregateID(city, period string) string {
+	return aggregateIDPrefix + period + "#" + strings.ToLower(city)
+}
+
+func aggregateKey(city, period, start string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id":        {S: aws.String(aggregateID(city, period))},
+		"timestamp": {S: aws.String(start)},
+	}
+}
+
+// appliedBefore reports whether an applied key's record was collected before horizon. Keys
+// without a readable timestamp are kept.
+func appliedBefore(key string, horizon time.Time) bool {
+	i := strings.LastIndex(key, "@")
+	if i < 0 {
+		return false
+	}
+	collected, err := time.Parse(time.RFC3339, key[i+1:])
+	return err == nil && collected.Before(horizon)
+}
+
+// aggregateFingerprint identifies the values a record contributed
+func aggregateFingerprint(values models.AggregateValues) string {
+	return strconv.FormatFloat(values.Temperature, 'g', -1, 64) + "|" +
+		strconv.FormatFloat(values.Humidity, 'g', -1, 64) + "|" +
+		strconv.FormatFloat(values.Pressure, 'g', -1, 64) + "|" +
+		strconv.FormatFloat(values.WindSpeed, 'g', -1, 64) + "|" +
+		strconv.FormatFloat(values.Precipitation, 'g', -1, 64)
+}
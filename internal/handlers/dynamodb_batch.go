@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/metrics"
+	"github.com/weather-lambda/internal/models"
+)
+
+// BatchWriteFailuresMetric counts records a batch write gave up on
+const BatchWriteFailuresMetric = "BatchWriteFailures"
+
+// maxBatchWriteItems is the most items DynamoDB accepts in one BatchWriteItem call
+const maxBatchWriteItems = 25
+
+const (
+	// defaultBatchWriteConcurrency is the number of batches in flight when none is configured
+	defaultBatchWriteConcurrency = 4
+	// defaultBatchWriteAttempts bounds retries of unprocessed items when none is configured
+	defaultBatchWriteAttempts = 8
+)
+
+// BatchWriteOptions configures a bulk write
+type BatchWriteOptions struct {
+	// Concurrency is the number of BatchWriteItem calls in flight; defaults to 4
+	Concurrency int
+	// MaxAttempts bounds the calls made for one batch, including retries; defaults to 8
+	MaxAttempts int
+}
+
+// RecordWriteFailure is a record a bulk write could not store
+type RecordWriteFailure struct {
+	ID        string
+	Timestamp string
+	Err       error
+}
+
+// BatchWriteResult reports the outcome of a bulk write
+type BatchWriteResult struct {
+	Written int
+	Failed  []RecordWriteFailure
+}
+
+// BatchWriteError is returned when some records of a bulk write were not stored
+type BatchWriteError struct {
+	Failures []RecordWriteFailure
+}
+
+func (e *BatchWriteError) Error() string {
+	ids := make([]string, 0, len(e.Failures))
+	for i, failure := range e.Failures {
+		if i == 3 {
+			ids = append(ids, "...")
+			break
+		}
+		ids = append(ids, failure.ID+"@"+failure.Timestamp)
+	}
+	return fmt.Sprintf("failed to write %d weather records (%s): %v", len(e.Failures), strings.Join(ids, ", "), e.Failures[0].Err)
+}
+
+// BatchStoreWeatherRecords writes records with BatchWriteItem, returning a *BatchWriteError
+// listing the records that could not be stored
+func (h *DynamoDBHandler) BatchStoreWeatherRecords(ctx context.Context, records []*models.WeatherRecord) error {
+	result := h.BatchWriteWeatherRecords(ctx, records, BatchWriteOptions{})
+	if len(result.Failed) > 0 {
+		return &BatchWriteError{Failures: result.Failed}
+	}
+	return nil
+}
+
+// BatchWriteWeatherRecords groups records into 25-item BatchWriteItem calls and runs them
+// concurrently. Unprocessed items and throttled calls are retried with backoff; records still
+// unwritten after the last attempt are reported in Failed rather than dropped. Records sharing
+// an id and timestamp are collapsed to the last one, as successive puts would leave it.
+func (h *DynamoDBHandler) BatchWriteWeatherRecords(ctx context.Context, records []*models.WeatherRecord, opts BatchWriteOptions) *BatchWriteResult {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchWriteConcurrency
+	}
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultBatchWriteAttempts
+	}
+
+	result := &BatchWriteResult{}
+	var mu sync.Mutex
+	report := func(written int, failures []RecordWriteFailure) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Written += written
+		result.Failed = append(result.Failed, failures...)
+	}
+
+	// Marshal up front so invalid records fail individually instead of spoiling their batch
+	index := make(map[string]int)
+	var requests []*dynamodb.WriteRequest
+	superseded := 0
+	for _, r := range records {
+		av, err := marshalWeatherRecord(r)
+		if err != nil {
+			report(0, []RecordWriteFailure{{ID: r.ID, Timestamp: r.Timestamp, Err: err}})
+			continue
+		}
+		request := &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: av}}
+		key := writeRequestKey(request)
+		if i, ok := index[key]; ok {
+			// BatchWriteItem rejects duplicate keys in one call
+			requests[i] = request
+			superseded++
+			continue
+		}
+		index[key] = len(requests)
+		requests = append(requests, request)
+	}
+	result.Written += superseded
+
+	batches := make(chan []*dynamodb.WriteRequest)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				written, failures := h.writeBatch(ctx, batch, attempts)
+				report(written, failures)
+			}
+		}()
+	}
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(requests) {
+			end = len(requests)
+		}
+		batches <- requests[start:end]
+	}
+	close(batches)
+	wg.Wait()
+
+	if len(result.Failed) > 0 {
+		metrics.Count(BatchWriteFailuresMetric, len(result.Failed), nil)
+	}
+	return result
+}
+
+// writeBatch writes one batch, retrying unprocessed items, and returns how many were written
+// along with the items that were not
+func (h *DynamoDBHandler) writeBatch(ctx context.Context, batch []*dynamodb.WriteRequest, attempts int) (int, []RecordWriteFailure) {
+	pending := batch
+	var lastErr error
+	for attempt := 0; attempt < attempts && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(batchWriteBackoff(attempt)):
+			case <-ctx.Done():
+				return len(batch) - len(pending), writeFailures(pending, ctx.Err())
+			}
+		}
+
+		output, err := h.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{h.tableName: pending},
+		})
+		if err != nil {
+			lastErr = fmt.Errorf("failed to batch write items to DynamoDB: %w", awsError(err))
+			if isThrottlingError(err) {
+				continue // Nothing in the call was written; retry it whole
+			}
+			return len(batch) - len(pending), writeFailures(pending, lastErr)
+		}
+
+		pending = output.UnprocessedItems[h.tableName]
+		lastErr = errs.Mark(fmt.Errorf("item left unprocessed by DynamoDB after %d attempts", attempts), errs.ErrThrottled)
+	}
+	return len(batch) - len(pending), writeFailures(pending, lastErr)
+}
+
+// batchWriteBackoff doubles from 50ms, capped at 5s
+func batchWriteBackoff(attempt int) time.Duration {
+	backoff := time.Duration(50<<attempt) * time.Millisecond
+	if backoff > 5*time.Second {
+		backoff = 5 * time.Second
+	}
+	return backoff
+}
+
+func writeFailures(requests []*dynamodb.WriteRequest, err error) []RecordWriteFailure {
+	failures := make([]RecordWriteFailure, 0, len(requests))
+	for _, request := range requests {
+		item := request.PutRequest.Item
+		failures = append(failures, RecordWriteFailure{
+			ID:        aws.StringValue(item["id"].S),
+			Timestamp: aws.StringValue(item["timestamp"].S),
+			Err:       err,
+		})
+	}
+	return failures
+}
+
+// writeRequestKey identifies a put request by its primary key
+func writeRequestKey(request *dynamodb.WriteRequest) string {
+	item := request.PutRequest.Item
+	return aws.StringValue(item["id"].S) + "\x00" + aws.StringValue(item["timestamp"].S)
+}
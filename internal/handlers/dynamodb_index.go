@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/weather-lambda/internal/models"
+)
+
+// CityTimeIndex is the GSI keyed on cityName and observedAt that serves per-city queries.
+// Items without observedAt, such as provider cache entries, are left out of it.
+const CityTimeIndex = "cityName-observedAt-index"
+
+// observedAtAttribute holds the provider's observation time (dt) in Unix seconds, the GSI sort
+// key. Records stored without one fall back to their collection time.
+const observedAtAttribute = "observedAt"
+
+// marshalWeatherRecord converts a record to a DynamoDB item, adding the index attributes
+func marshalWeatherRecord(record *models.WeatherRecord) (map[string]*dynamodb.AttributeValue, error) {
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal weather record: %w", err)
+	}
+	if observedAt, ok := recordObservedAt(record); ok {
+		av[observedAtAttribute] = unixAttribute(observedAt)
+	}
+	return av, nil
+}
+
+// recordObservedAt returns when the provider observed a record, or its collection time when
+// the record has no observation time
+func recordObservedAt(record *models.WeatherRecord) (time.Time, bool) {
+	if record.ObservedAt > 0 {
+		return time.Unix(record.ObservedAt, 0), true
+	}
+	return collectionTime(record.Timestamp, record.CreatedAt)
+}
+
+// collectionTime returns the time a record was collected, from its timestamp or creation time
+func collectionTime(timestamp string, createdAt time.Time) (time.Time, bool) {
+	if ts, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return ts, true
+	}
+	return createdAt, !createdAt.IsZero()
+}
+
+func unixAttribute(t time.Time) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(t.Unix(), 10))}
+}
+
+// IndexBackfillResult summarizes a backfill of the index attributes
+type IndexBackfillResult struct {
+	Scanned int `json:"scanned"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// BackfillIndexAttributes sets observedAt on records written before the city index existed, so
+// they become visible to index queries. Those records don't carry the provider's observation
+// time, so they are indexed by collection time. It only touches records missing the attribute,
+// so it can be rerun safely; dryRun counts the records without updating them. segments sets
+// the number of parallel scan workers.
+func (h *DynamoDBHandler) BackfillIndexAttributes(ctx context.Context, dryRun bool, segments int) (*IndexBackfillResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scan := h.ScanTable(ctx, ScanOptions{
+		Segments:             segments,
+		FilterExpression:     "attribute_exists(cityName) AND attribute_not_exists(#observedAt)",
+		ProjectionExpression: "id, #ts, createdAt",
+		ExpressionAttributeNames: map[string]*string{
+			"#observedAt": aws.String(observedAtAttribute),
+			"#ts":         aws.String("timestamp"),
+		},
+	})
+
+	result := &IndexBackfillResult{}
+	var updateErr error
+	for item := range scan.Items {
+		if updateErr != nil {
+			continue // Drain until the cancelled workers close the channel
+		}
+		result.Scanned++
+		var key struct {
+			ID        string    `dynamodbav:"id"`
+			Timestamp string    `dynamodbav:"timestamp"`
+			CreatedAt time.Time `dynamodbav:"createdAt"`
+		}
+		if err := dynamodbattribute.UnmarshalMap(item, &key); err != nil {
+			log.Printf("Skipping unreadable record %v: %v", item["id"], err)
+			result.Skipped++
+			continue
+		}
+		observedAt, ok := collectionTime(key.Timestamp, key.CreatedAt)
+		if !ok {
+			log.Printf("Skipping record %s with no collection time", key.ID)
+			result.Skipped++
+			continue
+		}
+		if dryRun {
+			result.Updated++
+			continue
+		}
+
+		if err := h.setObservedAt(ctx, key.ID, key.Timestamp, observedAt); err != nil {
+			updateErr = err
+			cancel()
+			continue
+		}
+		result.Updated++
+	}
+	if updateErr != nil {
+		return result, updateErr
+	}
+	if err := scan.Err(); err != nil {
+		return result, fmt.Errorf("failed to scan for records to backfill: %w", err)
+	}
+	return result, ctx.Err()
+}
+
+// setObservedAt adds the sort key attribute to an existing record; records deleted meanwhile are left alone
+func (h *DynamoDBHandler) setObservedAt(ctx context.Context, id, timestamp string, observedAt time.Time) error {
+	_, err := h.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(h.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String(id)},
+			"timestamp": {S: aws.String(timestamp)},
+		},
+		UpdateExpression:         aws.String("SET #observedAt = :observedAt"),
+		ConditionExpression:      aws.String("attribute_exists(id)"),
+		ExpressionAttributeNames: map[string]*string{"#observedAt": aws.String(observedAtAttribute)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":observedAt": unixAttribute(observedAt),
+		},
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil
+		}
+		return fmt.Errorf("failed to backfill record %s: %w", id, awsError(err))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// Latest items share the records table, one per city under a fixed sort key. The reading is
+// nested under record, so the item has no cityName or ttl: it stays out of the city index and
+// the stream aggregates, and the last reading remains available after collection stops.
+const (
+	latestIDPrefix  = "latest#"
+	latestTimestamp = "latest"
+)
+
+var _ storage.LatestStore = (*DynamoDBHandler)(nil)
+
+// PutLatestWeather makes record the city's latest reading unless a reading observed at the
+// same time or later is already stored. It reports whether the snapshot was updated.
+func (h *DynamoDBHandler) PutLatestWeather(ctx context.Context, record *models.WeatherRecord) (bool, error) {
+	observedAt, ok := recordObservedAt(record)
+	if !ok {
+		return false, fmt.Errorf("record %s has no observation time", record.ID)
+	}
+	av, err := marshalWeatherRecord(record)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = h.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(h.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":              {S: aws.String(latestID(record.CityName))},
+			"timestamp":       {S: aws.String(latestTimestamp)},
+			"observationTime": unixAttribute(observedAt),
+			"record":          {M: av},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id) OR observationTime < :observationTime"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":observationTime": unixAttribute(observedAt),
+		},
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to put latest weather for %s: %w", record.CityName, awsError(err))
+	}
+	return true, nil
+}
+
+// GetLatestWeather returns the city's latest reading with a single item read
+func (h *DynamoDBHandler) GetLatestWeather(ctx context.Context, city string) (*models.WeatherRecord, error) {
+	result, err := h.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(h.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String(latestID(city))},
+			"timestamp": {S: aws.String(latestTimestamp)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest weather for %s: %w", city, awsError(err))
+	}
+	if result.Item["record"] == nil || result.Item["record"].M == nil {
+		return nil, fmt.Errorf("no latest weather for %s: %w", city, storage.ErrNotFound)
+	}
+
+	record, err := decodeWeatherRecord(result.Item["record"].M)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode latest weather for %s: %w", city, err)
+	}
+	return record, nil
+}
+
+func latestID(city string) string {
+	return latestIDPrefix + strings.ToLower(city)
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/models"
+)
+
+// RecordPage is one page of query results
+type RecordPage struct {
+	Records []*models.WeatherRecord
+	// NextToken resumes the query after this page; empty when the query is exhausted
+	NextToken string
+}
+
+// pageToken is the decoded form of a continuation token. Query identifies the query the
+// token belongs to, so a token can't be replayed against a different city or ID.
+type pageToken struct {
+	Query string                 `json:"q"`
+	Key   map[string]interface{} `json:"k"`
+}
+
+// QueryWeatherRecordsByCityPage returns up to limit of a city's unexpired records, most recent
+// first, starting after token. A limit of zero or less reads every remaining record.
+func (h *DynamoDBHandler) QueryWeatherRecordsByCityPage(ctx context.Context, cityName string, limit int64, token string) (*RecordPage, error) {
+	return h.queryPage(ctx, "city:"+cityName, &dynamodb.QueryInput{
+		TableName:              aws.String(h.tableName),
+		IndexName:              aws.String(CityTimeIndex),
+		KeyConditionExpression: aws.String("cityName = :city"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":city": {S: aws.String(cityName)},
+		},
+		ScanIndexForward: aws.Bool(false), // Most recent first
+	}, limit, token)
+}
+
+// QueryRecentWeatherRecordsPage returns up to limit of the unexpired records stored under id,
+// most recent first, starting after token. A limit of zero or less reads every remaining record.
+func (h *DynamoDBHandler) QueryRecentWeatherRecordsPage(ctx context.Context, id string, limit int64, token string) (*RecordPage, error) {
+	return h.queryPage(ctx, "id:"+id, &dynamodb.QueryInput{
+		TableName:              aws.String(h.tableName),
+		KeyConditionExpression: aws.String("id = :id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id": {S: aws.String(id)},
+		},
+		ScanIndexForward: aws.Bool(false), // Sort by timestamp descending (most recent first)
+	}, limit, token)
+}
+
+// queryPage runs the query until limit matching records are gathered or the results run out.
+// Expired records that TTL hasn't deleted yet are filtered out, so a single DynamoDB page may
+// hold fewer matches than its Limit and the query keeps following LastEvaluatedKey.
+func (h *DynamoDBHandler) queryPage(ctx context.Context, query string, input *dynamodb.QueryInput, limit int64, token string) (*RecordPage, error) {
+	if token != "" {
+		startKey, err := decodePageToken(query, token)
+		if err != nil {
+			return nil, err
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	input.FilterExpression = aws.String("attribute_not_exists(#ttl) OR #ttl = :noExpiry OR #ttl >= :now")
+	if input.ExpressionAttributeNames == nil {
+		input.ExpressionAttributeNames = map[string]*string{}
+	}
+	input.ExpressionAttributeNames["#ttl"] = aws.String("ttl")
+	input.ExpressionAttributeValues[":noExpiry"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	input.ExpressionAttributeValues[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))}
+
+	page := &RecordPage{}
+	for {
+		if limit > 0 {
+			// Never evaluate more items than are still needed, so the last evaluated key
+			// is exactly where the next page must start
+			input.Limit = aws.Int64(limit - int64(len(page.Records)))
+		}
+
+		result, err := h.client.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query DynamoDB table: %w", awsError(err))
+		}
+		for _, item := range result.Items {
+			record, err := decodeWeatherRecord(item)
+			if err != nil {
+				continue // Skip records that can't be migrated; counted by decodeWeatherRecord
+			}
+			page.Records = append(page.Records, record)
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return page, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+		if limit > 0 && int64(len(page.Records)) >= limit {
+			if page.NextToken, err = encodePageToken(query, result.LastEvaluatedKey); err != nil {
+				return nil, err
+			}
+			return page, nil
+		}
+	}
+}
+
+// encodePageToken wraps a LastEvaluatedKey into an opaque, URL-safe token
+func encodePageToken(query string, key map[string]*dynamodb.AttributeValue) (string, error) {
+	var values map[string]interface{}
+	if err := dynamodbattribute.UnmarshalMap(key, &values); err != nil {
+		return "", fmt.Errorf("failed to encode continuation token: %w", err)
+	}
+	body, err := json.Marshal(pageToken{Query: query, Key: values})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode continuation token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+// decodePageToken recovers the ExclusiveStartKey from a token issued for the same query
+func decodePageToken(query, token string) (map[string]*dynamodb.AttributeValue, error) {
+	body, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errs.Invalid("nextToken", "invalid continuation token")
+	}
+	var decoded pageToken
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded.Query != query || len(decoded.Key) == 0 {
+		return nil, errs.Invalid("nextToken", "invalid continuation token")
+	}
+
+	key, err := dynamodbattribute.MarshalMap(decoded.Key)
+	if err != nil {
+		return nil, errs.Invalid("nextToken", "invalid continuation token")
+	}
+	return key, nil
+}
+
+// RecordIterator streams query results, fetching a page at a time as the consumer advances
+type RecordIterator struct {
+	fetch   func(token string) (*RecordPage, error)
+	token   string
+	started bool
+	records []*models.WeatherRecord
+	current *models.WeatherRecord
+	err     error
+}
+
+// IterateWeatherRecordsByCity streams a city's unexpired records, most recent first, reading
+// pageSize records per request
+func (h *DynamoDBHandler) IterateWeatherRecordsByCity(ctx context.Context, cityName string, pageSize int64) *RecordIterator {
+	return &RecordIterator{fetch: func(token string) (*RecordPage, error) {
+		return h.QueryWeatherRecordsByCityPage(ctx, cityName, pageSize, token)
+	}}
+}
+
+// IterateRecentWeatherRecords streams the unexpired records stored under id, most recent first
+func (h *DynamoDBHandler) IterateRecentWeatherRecords(ctx context.Context, id string, pageSize int64) *RecordIterator {
+	return &RecordIterator{fetch: func(token string) (*RecordPage, error) {
+		return h.QueryRecentWeatherRecordsPage(ctx, id, pageSize, token)
+	}}
+}
+
+// Next advances to the next record, fetching the next page when the current one is used up.
+// It returns false when the results are exhausted or an error occurred.
+func (it *RecordIterator) Next() bool {
+	for len(it.records) == 0 {
+		if it.err != nil || (it.started && it.token == "") {
+			it.current = nil
+			return false
+		}
+		page, err := it.fetch(it.token)
+		it.started = true
+		if err != nil {
+			it.err = err
+			continue
+		}
+		it.records, it.token = page.Records, page.NextToken
+	}
+
+	it.current, it.records = it.records[0], it.records[1:]
+	return true
+}
+
+// Value returns the current record
+func (it *RecordIterator) Value() *models.WeatherRecord {
+	return it.current
+}
+
+// Err returns the error that stopped iteration, if any
+func (it *RecordIterator) Err() error {
+	return it.err
+}
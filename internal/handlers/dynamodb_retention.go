@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// TTLUpdateOptions selects the records whose TTL is recomputed
+type TTLUpdateOptions struct {
+	// Cities limits the update to these cities; empty means every city
+	Cities []string
+	// DryRun counts the records that would change without updating them
+	DryRun bool
+	// Segments is the number of parallel scan workers
+	Segments int
+}
+
+// TTLUpdateResult summarizes a TTL recompute
+type TTLUpdateResult struct {
+	Scanned   int `json:"scanned"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+}
+
+// RecomputeTTL rewrites the ttl of existing records with expiresAt, which receives a record's
+// city and collection time and returns the new TTL in Unix seconds, 0 meaning never expire.
+// Records whose new TTL is already past are removed by DynamoDB's TTL sweeper.
+func (h *DynamoDBHandler) RecomputeTTL(ctx context.Context, expiresAt func(city string, collected time.Time) int64, opts TTLUpdateOptions) (*TTLUpdateResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scanOpts := ScanOptions{
+		Segments:             opts.Segments,
+		FilterExpression:     "attribute_exists(cityName)",
+		ProjectionExpression: "id, #ts, cityName, createdAt, #ttl",
+		ExpressionAttributeNames: map[string]*string{
+			"#ts":  aws.String("timestamp"),
+			"#ttl": aws.String("ttl"),
+		},
+	}
+	if len(opts.Cities) > 0 {
+		scanOpts.ExpressionAttributeValues = make(map[string]*dynamodb.AttributeValue, len(opts.Cities))
+		for i, city := range opts.Cities {
+			scanOpts.ExpressionAttributeValues[":city"+strconv.Itoa(i)] = &dynamodb.AttributeValue{S: aws.String(city)}
+		}
+		scanOpts.FilterExpression = "cityName IN (" + joinKeys(len(opts.Cities)) + ")"
+	}
+	scan := h.ScanTable(ctx, scanOpts)
+
+	result := &TTLUpdateResult{}
+	var updateErr error
+	for item := range scan.Items {
+		if updateErr != nil {
+			continue // Drain until the cancelled workers close the channel
+		}
+		result.Scanned++
+		var key struct {
+			ID        string    `dynamodbav:"id"`
+			Timestamp string    `dynamodbav:"timestamp"`
+			CityName  string    `dynamodbav:"cityName"`
+			CreatedAt time.Time `dynamodbav:"createdAt"`
+			TTL       int64     `dynamodbav:"ttl"`
+		}
+		if err := dynamodbattribute.UnmarshalMap(item, &key); err != nil {
+			log.Printf("Skipping unreadable record %v: %v", item["id"], err)
+			result.Skipped++
+			continue
+		}
+		collected, ok := collectionTime(key.Timestamp, key.CreatedAt)
+		if !ok {
+			log.Printf("Skipping record %s with no collection time", key.ID)
+			result.Skipped++
+			continue
+		}
+
+		ttl := expiresAt(key.CityName, collected)
+		if ttl == key.TTL {
+			result.Unchanged++
+			continue
+		}
+		if opts.DryRun {
+			result.Updated++
+			continue
+		}
+		if err := h.setTTL(ctx, key.ID, key.Timestamp, ttl); err != nil {
+			updateErr = err
+			cancel()
+			continue
+		}
+		result.Updated++
+	}
+	if updateErr != nil {
+		return result, updateErr
+	}
+	if err := scan.Err(); err != nil {
+		return result, fmt.Errorf("failed to scan records for TTL update: %w", err)
+	}
+	return result, ctx.Err()
+}
+
+// setTTL updates an existing record's ttl; records deleted meanwhile are left alone
+func (h *DynamoDBHandler) setTTL(ctx context.Context, id, timestamp string, ttl int64) error {
+	_, err := h.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(h.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String(id)},
+			"timestamp": {S: aws.String(timestamp)},
+		},
+		UpdateExpression:         aws.String("SET #ttl = :ttl"),
+		ConditionExpression:      aws.String("attribute_exists(id)"),
+		ExpressionAttributeNames: map[string]*string{"#ttl": aws.String("ttl")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":ttl": {N: aws.String(strconv.FormatInt(ttl, 10))},
+		},
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil
+		}
+		return fmt.Errorf("failed to update TTL of record %s: %w", id, awsError(err))
+	}
+	return nil
+}
+
+// joinKeys lists the :city0..:cityN placeholders for an IN condition
+func joinKeys(n int) string {
+	keys := ""
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			keys += ", "
+		}
+		keys += ":city" + strconv.Itoa(i)
+	}
+	return keys
+}
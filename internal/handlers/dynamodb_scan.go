@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/weather-lambda/internal/models"
+)
+
+const (
+	// defaultScanSegments is the number of parallel scan workers when none is configured
+	defaultScanSegments = 4
+
+	// minScanDelay and maxScanDelay bound the pause between requests once the table throttles
+	minScanDelay = 50 * time.Millisecond
+	maxScanDelay = 10 * time.Second
+)
+
+// ScanOptions configures a parallel scan
+type ScanOptions struct {
+	// Segments is the number of Segment/TotalSegments workers; defaults to 4
+	Segments int
+	// Buffer is how many items may wait in the channel before workers block; defaults to 0,
+	// so a slow consumer pauses the scan instead of letting it read ahead
+	Buffer int
+	// FilterExpression, ProjectionExpression and the expression attributes narrow the scan
+	FilterExpression          string
+	ProjectionExpression      string
+	ExpressionAttributeNames  map[string]*string
+	ExpressionAttributeValues map[string]*dynamodb.AttributeValue
+	// PageSize sets the scan Limit per request; zero leaves it to DynamoDB
+	PageSize int64
+}
+
+// ParallelScan streams the items of a segmented scan. Drain Items and then check Err.
+type ParallelScan struct {
+	// Items delivers scanned items from every segment in no particular order; it is closed
+	// once all segments finish, fail or the context is cancelled
+	Items <-chan map[string]*dynamodb.AttributeValue
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err returns the first error that stopped the scan; only meaningful once Items is closed
+func (s *ParallelScan) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *ParallelScan) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// ScanTable reads the whole table with concurrent segment workers. Cancel ctx to stop early;
+// workers stop at their next send or request.
+func (h *DynamoDBHandler) ScanTable(ctx context.Context, opts ScanOptions) *ParallelScan {
+	segments := opts.Segments
+	if segments <= 0 {
+		segments = defaultScanSegments
+	}
+	if opts.Buffer < 0 {
+		opts.Buffer = 0
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	items := make(chan map[string]*dynamodb.AttributeValue, opts.Buffer)
+	scan := &ParallelScan{Items: items}
+	throttle := &scanThrottle{}
+
+	var wg sync.WaitGroup
+	for segment := 0; segment < segments; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+			if err := h.scanSegment(ctx, opts, int64(segment), int64(segments), throttle, items); err != nil {
+				scan.fail(err)
+				cancel() // One failed segment makes the scan incomplete, so stop the rest
+			}
+		}(segment)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(items)
+	}()
+	return scan
+}
+
+// scanSegment reads one segment, retrying throttled requests from the same start key
+func (h *DynamoDBHandler) scanSegment(ctx context.Context, opts ScanOptions, segment, total int64, throttle *scanThrottle, items chan<- map[string]*dynamodb.AttributeValue) error {
+	input := &dynamodb.ScanInput{
+		TableName:     aws.String(h.tableName),
+		Segment:       aws.Int64(segment),
+		TotalSegments: aws.Int64(total),
+	}
+	if opts.FilterExpression != "" {
+		input.FilterExpression = aws.String(opts.FilterExpression)
+	}
+	if opts.ProjectionExpression != "" {
+		input.ProjectionExpression = aws.String(opts.ProjectionExpression)
+	}
+	if len(opts.ExpressionAttributeNames) > 0 {
+		input.ExpressionAttributeNames = opts.ExpressionAttributeNames
+	}
+	if len(opts.ExpressionAttributeValues) > 0 {
+		input.ExpressionAttributeValues = opts.ExpressionAttributeValues
+	}
+	if opts.PageSize > 0 {
+		input.Limit = aws.Int64(opts.PageSize)
+	}
+
+	for {
+		if err := throttle.wait(ctx); err != nil {
+			return nil // Cancelled: the caller or another segment already decided the outcome
+		}
+
+		result, err := h.client.ScanWithContext(ctx, input)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if isThrottlingError(err) {
+				throttle.backOff()
+				continue
+			}
+			return fmt.Errorf("failed to scan segment %d of %d: %w", segment, total, awsError(err))
+		}
+		throttle.ease()
+
+		for _, item := range result.Items {
+			select {
+			case items <- item:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// ScanWeatherRecords streams every weather record in the table, skipping provider cache
+// items and records that can't be decoded
+func (h *DynamoDBHandler) ScanWeatherRecords(ctx context.Context, opts ScanOptions) (<-chan *models.WeatherRecord, *ParallelScan) {
+	if opts.FilterExpression == "" {
+		opts.FilterExpression = "attribute_exists(cityName)"
+	} else {
+		opts.FilterExpression = "attribute_exists(cityName) AND (" + opts.FilterExpression + ")"
+	}
+	if opts.Buffer < 0 {
+		opts.Buffer = 0
+	}
+
+	scan := h.ScanTable(ctx, opts)
+	records := make(chan *models.WeatherRecord, opts.Buffer)
+	go func() {
+		defer close(records)
+		for item := range scan.Items {
+			record, err := decodeWeatherRecord(item)
+			if err != nil {
+				continue // Counted by decodeWeatherRecord
+			}
+			select {
+			case records <- record:
+			case <-ctx.Done():
+				// Keep draining so the workers can exit and Items closes
+			}
+		}
+	}()
+	return records, scan
+}
+
+// scanThrottle is a delay shared by all segment workers. It doubles each time DynamoDB
+// throttles a request and halves after each successful one, so the scan settles near the
+// rate the table's capacity allows.
+type scanThrottle struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (t *scanThrottle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	delay := t.delay
+	t.mu.Unlock()
+	if delay == 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *scanThrottle) backOff() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delay *= 2
+	if t.delay < minScanDelay {
+		t.delay = minScanDelay
+	}
+	if t.delay > maxScanDelay {
+		t.delay = maxScanDelay
+	}
+}
+
+func (t *scanThrottle) ease() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delay /= 2
+	if t.delay < minScanDelay {
+		t.delay = 0
+	}
+}
+
+// isThrottlingError reports whether DynamoDB rejected a request for exceeding capacity
+func isThrottlingError(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+	switch aerr.Code() {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException,
+		dynamodb.ErrCodeRequestLimitExceeded,
+		"ThrottlingException":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/weather-lambda/internal/models"
+)
+
+// DecodeStreamImage decodes a weather record from a DynamoDB stream image. It returns nil
+// without an error for items that aren't weather records, such as cache or summary items.
+func DecodeStreamImage(image map[string]events.DynamoDBAttributeValue) (*models.WeatherRecord, error) {
+	if _, ok := image["cityName"]; !ok {
+		return nil, nil
+	}
+
+	// Both types marshal to the DynamoDB JSON wire format, so JSON converts between them
+	body, err := json.Marshal(image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode stream image: %w", err)
+	}
+	var item map[string]*dynamodb.AttributeValue
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, fmt.Errorf("failed to convert stream image: %w", err)
+	}
+
+	return decodeWeatherRecord(item)
+}
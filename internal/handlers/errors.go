@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/weather-lambda/internal/errs"
+)
+
+// awsError classifies an AWS SDK error into the errs taxonomy, keeping its message. Errors
+// outside the taxonomy, such as malformed requests or a missing table or bucket, are
+// returned unchanged.
+func awsError(err error) error {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return err
+	}
+
+	if isThrottlingError(err) {
+		return errs.Mark(err, errs.ErrThrottled)
+	}
+	switch aerr.Code() {
+	case dynamodb.ErrCodeConditionalCheckFailedException, dynamodb.ErrCodeTransactionConflictException:
+		return errs.Mark(err, errs.ErrConflict)
+	case s3.ErrCodeNoSuchKey, "NotFound":
+		return errs.Mark(err, errs.ErrNotFound)
+	case dynamodb.ErrCodeResourceNotFoundException, s3.ErrCodeNoSuchBucket:
+		// A missing table or bucket is a deployment fault, not a missing resource of the
+		// caller's, so it stays unclassified and surfaces as an internal error
+		return err
+	case "SlowDown":
+		return errs.Mark(err, errs.ErrThrottled)
+	case request.ErrCodeRequestError, request.ErrCodeResponseTimeout:
+		return errs.Mark(err, errs.ErrUpstreamUnavailable)
+	}
+
+	var failure awserr.RequestFailure
+	if errors.As(err, &failure) && failure.StatusCode() >= http.StatusInternalServerError {
+		return errs.Mark(err, errs.ErrUpstreamUnavailable)
+	}
+	return err
+}
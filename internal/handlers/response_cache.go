@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// Cache items share the records table, keyed apart from weather records
+const (
+	responseCacheIDPrefix  = "provider-cache#"
+	responseCacheTimestamp = "latest"
+)
+
+// S3ResponseCache persists cached weather API responses as S3 objects
+type S3ResponseCache struct {
+	handler *S3Handler
+	prefix  string
+}
+
+// NewS3ResponseCache creates a response cache stored under prefix in the handler's bucket
+func NewS3ResponseCache(handler *S3Handler, prefix string) *S3ResponseCache {
+	return &S3ResponseCache{
+		handler: handler,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// Get retrieves the cached response for a location, returning nil when none is stored
+func (c *S3ResponseCache) Get(location string) (*models.CachedResponse, error) {
+	body, err := c.handler.GetObject(c.key(location))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cached response from S3: %w", err)
+	}
+
+	var entry models.CachedResponse
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Put stores the cached response for a location
+func (c *S3ResponseCache) Put(entry *models.CachedResponse) error {
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+
+	err = c.handler.StoreObject(c.key(entry.Location), jsonData, "application/json")
+	if err != nil {
+		return fmt.Errorf("failed to upload cached response to S3: %w", err)
+	}
+
+	return nil
+}
+
+func (c *S3ResponseCache) key(location string) string {
+	return fmt.Sprintf("%s/%s.json", c.prefix, strings.ToLower(location))
+}
+
+// DynamoDBResponseCache persists cached weather API responses as items in the records table
+type DynamoDBResponseCache struct {
+	handler *DynamoDBHandler
+}
+
+// NewDynamoDBResponseCache creates a response cache stored in the handler's table
+func NewDynamoDBResponseCache(handler *DynamoDBHandler) *DynamoDBResponseCache {
+	return &DynamoDBResponseCache{handler: handler}
+}
+
+// Get retrieves the cached response for a location, returning nil when none is stored
+func (c *DynamoDBResponseCache) Get(location string) (*models.CachedResponse, error) {
+	result, err := c.handler.client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(c.handler.tableName),
+		Key:       responseCacheKey(location),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached response from DynamoDB: %w", awsError(err))
+	}
+
+	payload, ok := result.Item["payload"]
+	if !ok || payload.S == nil {
+		return nil, nil
+	}
+
+	var entry models.CachedResponse
+	if err := json.Unmarshal([]byte(*payload.S), &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Put stores the cached response for a location
+func (c *DynamoDBResponseCache) Put(entry *models.CachedResponse) error {
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+
+	// The payload is kept as a JSON string so cache items never look like weather records
+	item := responseCacheKey(entry.Location)
+	item["payload"] = &dynamodb.AttributeValue{S: aws.String(string(jsonData))}
+
+	_, err = c.handler.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(c.handler.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put cached response to DynamoDB: %w", awsError(err))
+	}
+
+	return nil
+}
+
+func responseCacheKey(location string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {
+			S: aws.String(responseCacheIDPrefix + strings.ToLower(location)),
+		},
+		"timestamp": {
+			S: aws.String(responseCacheTimestamp),
+		},
+	}
+}
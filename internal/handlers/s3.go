@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,52 +13,122 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/weather-lambda/internal/config"
 	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/schema"
+	"github.com/weather-lambda/internal/storage"
 )
 
-// S3Handler handles S3 operations
+// S3Handler handles S3 operations. The archive logic runs on any storage.BlobStore,
+// so the same layouts and compression work against a local directory.
 type S3Handler struct {
-	client *s3.S3
-	bucket string
+	store       storage.BlobStore
+	keyLayout   KeyLayout
+	keyPrefix   string
+	compression Compression
+}
+
+// S3Option configures an S3Handler
+type S3Option func(*S3Handler)
+
+// WithKeyLayout sets the key scheme used for new objects
+func WithKeyLayout(layout KeyLayout) S3Option {
+	return func(h *S3Handler) {
+		h.keyLayout = layout
+	}
+}
+
+// WithKeyPrefix sets the top-level prefix for archived weather data
+func WithKeyPrefix(prefix string) S3Option {
+	return func(h *S3Handler) {
+		h.keyPrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// WithCompression sets the encoding used for new objects
+func WithCompression(compression Compression) S3Option {
+	return func(h *S3Handler) {
+		h.compression = compression
+	}
 }
 
 // NewS3Handler creates a new S3 handler
-func NewS3Handler(cfg *config.Config, sess *session.Session) *S3Handler {
-	return &S3Handler{
-		client: s3.New(sess),
-		bucket: cfg.AWS.S3Bucket,
+func NewS3Handler(cfg *config.Config, sess *session.Session, opts ...S3Option) *S3Handler {
+	return NewS3HandlerFromStore(NewS3BlobStore(s3.New(sess), cfg.AWS.S3Bucket), opts...)
+}
+
+// NewS3HandlerFromStore creates a handler archiving to any blob store, such as a local directory
+func NewS3HandlerFromStore(store storage.BlobStore, opts ...S3Option) *S3Handler {
+	h := &S3Handler{
+		store:       store,
+		keyLayout:   KeyLayoutLegacy,
+		keyPrefix:   DefaultKeyPrefix,
+		compression: CompressionNone,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ObjectKey returns the S3 key weather data is stored under with the configured layout and compression
+func (h *S3Handler) ObjectKey(data *models.S3WeatherData) string {
+	return h.keyLayout.ObjectKey(h.keyPrefix, data) + h.compression.KeySuffix()
+}
+
+// DayPrefix returns the key prefix holding a city's objects for a day with the configured layout
+func (h *S3Handler) DayPrefix(city string, day time.Time) string {
+	return h.keyLayout.DayPrefix(h.keyPrefix, city, day)
+}
+
+// KeyLayout returns the key scheme used for new objects
+func (h *S3Handler) KeyLayout() KeyLayout {
+	return h.keyLayout
+}
+
+// Compression returns the encoding used for new objects
+func (h *S3Handler) Compression() Compression {
+	return h.compression
 }
 
 // StoreWeatherData stores weather data to S3
 func (h *S3Handler) StoreWeatherData(data *models.S3WeatherData) error {
-	// Convert data to JSON
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	// Create S3 key using the configured layout
+	return h.putWeatherData(h.ObjectKey(data), data, h.compression)
+}
+
+// RewriteWeatherData replaces the object at key in place, keeping the encoding its suffix implies
+func (h *S3Handler) RewriteWeatherData(key string, data *models.S3WeatherData) error {
+	return h.putWeatherData(key, data, CompressionFromKey(key))
+}
+
+func (h *S3Handler) putWeatherData(key string, data *models.S3WeatherData, compression Compression) error {
+	// Convert data to JSON, keeping plain objects human readable
+	var jsonData []byte
+	var err error
+	if compression == CompressionNone {
+		jsonData, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		jsonData, err = json.Marshal(data)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal weather data: %w", err)
 	}
 
-	// Create S3 key with date-based prefix for organization
-	now := time.Now()
-	key := fmt.Sprintf("weather-data/%s/%s/%s.json",
-		now.Format("2006"),
-		now.Format("01-02"),
-		data.ID,
-	)
+	body, err := compression.Compress(jsonData)
+	if err != nil {
+		return err
+	}
 
 	// Upload to S3
-	_, err = h.client.PutObject(&s3.PutObjectInput{
-		Bucket:      aws.String(h.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(jsonData),
-		ContentType: aws.String("application/json"),
-		Metadata: map[string]*string{
-			"city":        aws.String(data.CityName),
-			"country":     aws.String(data.Country),
-			"temperature": aws.String(fmt.Sprintf("%.2f", data.Temperature)),
-			"timestamp":   aws.String(data.Timestamp),
+	err = h.store.Put(context.Background(), key, body, storage.PutOptions{
+		ContentType:     "application/json",
+		ContentEncoding: compression.ContentEncoding(),
+		Metadata: map[string]string{
+			"city":        data.CityName,
+			"country":     data.Country,
+			"temperature": fmt.Sprintf("%.2f", data.Temperature),
+			"timestamp":   data.Timestamp,
 		},
 	})
-
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
@@ -64,34 +136,78 @@ func (h *S3Handler) StoreWeatherData(data *models.S3WeatherData) error {
 	return nil
 }
 
-// GetWeatherData retrieves weather data from S3
+// GetWeatherData retrieves weather data from S3, reading compressed and plain objects alike
 func (h *S3Handler) GetWeatherData(key string) (*models.S3WeatherData, error) {
-	result, err := h.client.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(h.bucket),
-		Key:    aws.String(key),
-	})
+	return h.getWeatherData(context.Background(), key)
+}
+
+func (h *S3Handler) getWeatherData(ctx context.Context, key string) (*models.S3WeatherData, error) {
+	body, err := h.store.Get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get object from S3: %w", err)
 	}
-	defer result.Body.Close()
+
+	jsonData, err := Decompress(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
 
 	var data models.S3WeatherData
-	if err := json.NewDecoder(result.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode weather data: %w", err)
+	if err := schema.DecodeJSON(jsonData, &data); err != nil {
+		reportUnmigratable("s3", key, err)
+		return nil, fmt.Errorf("failed to decode weather data %s: %w", key, err)
 	}
 
 	return &data, nil
 }
 
-// ListWeatherData lists weather data files in S3
+// ListWeatherData lists weather data files in S3, following continuation tokens past the first 1000 objects
 func (h *S3Handler) ListWeatherData(prefix string) ([]*s3.Object, error) {
-	result, err := h.client.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket: aws.String(h.bucket),
-		Prefix: aws.String(prefix),
+	var objects []*s3.Object
+	err := h.WalkWeatherData(prefix, func(object storage.Object) bool {
+		objects = append(objects, &s3.Object{
+			Key:          aws.String(object.Key),
+			Size:         aws.Int64(object.Size),
+			LastModified: aws.Time(object.LastModified),
+		})
+		return true
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list objects in S3: %w", err)
+		return nil, err
 	}
 
-	return result.Contents, nil
-}
\ No newline at end of file
+	return objects, nil
+}
+
+// WalkWeatherData calls fn for every object under prefix, following continuation tokens.
+// Returning false from fn stops the walk.
+func (h *S3Handler) WalkWeatherData(prefix string, fn func(object storage.Object) bool) error {
+	return h.store.List(context.Background(), storage.ListOptions{Prefix: prefix}, func(page storage.ListPage) bool {
+		for _, object := range page.Objects {
+			if !fn(object) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// CopyWeatherData copies an object to a new key within the bucket, keeping its metadata
+func (h *S3Handler) CopyWeatherData(sourceKey, destinationKey string) error {
+	return h.store.Copy(context.Background(), sourceKey, destinationKey)
+}
+
+// DeleteWeatherData deletes an object from S3
+func (h *S3Handler) DeleteWeatherData(key string) error {
+	return h.store.Delete(context.Background(), key)
+}
+
+// StoreObject uploads an arbitrary object, such as a curated analytics file
+func (h *S3Handler) StoreObject(key string, body []byte, contentType string) error {
+	return h.store.Put(context.Background(), key, body, storage.PutOptions{ContentType: contentType})
+}
+
+// GetObject downloads an object's raw bytes. A missing key returns an error wrapping storage.ErrNotFound.
+func (h *S3Handler) GetObject(key string) ([]byte, error) {
+	return h.store.Get(context.Background(), key)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// S3BlobStore is the S3 implementation of storage.BlobStore
+type S3BlobStore struct {
+	client S3Client
+	bucket string
+}
+
+var _ storage.BlobStore = (*S3BlobStore)(nil)
+
+// NewS3BlobStore creates a blob store over one bucket
+func NewS3BlobStore(client S3Client, bucket string) *S3BlobStore {
+	return &S3BlobStore{client: client, bucket: bucket}
+}
+
+// Put uploads an object
+func (s *S3BlobStore) Put(ctx context.Context, key string, body []byte, opts storage.PutOptions) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(opts.ContentEncoding)
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = aws.StringMap(opts.Metadata)
+	}
+
+	if _, err := s.client.PutObjectWithContext(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload %s to S3: %w", key, awsError(err))
+	}
+	return nil
+}
+
+// Get downloads an object's raw bytes
+func (s *S3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	result, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, fmt.Errorf("failed to get object %s from S3: %w", key, storage.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object %s from S3: %w", key, awsError(err))
+	}
+	defer result.Body.Close()
+
+	body, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// List pages through ListObjectsV2, following continuation tokens
+func (s *S3BlobStore) List(ctx context.Context, opts storage.ListOptions, fn func(page storage.ListPage) bool) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(opts.Prefix),
+	}
+	if opts.StartAfter != "" {
+		input.StartAfter = aws.String(opts.StartAfter)
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+
+	err := s.client.ListObjectsV2PagesWithContext(ctx, input, func(output *s3.ListObjectsV2Output, lastPage bool) bool {
+		var page storage.ListPage
+		for _, object := range output.Contents {
+			page.Objects = append(page.Objects, storage.Object{
+				Key:          aws.StringValue(object.Key),
+				Size:         aws.Int64Value(object.Size),
+				LastModified: aws.TimeValue(object.LastModified),
+			})
+		}
+		for _, prefix := range output.CommonPrefixes {
+			page.CommonPrefixes = append(page.CommonPrefixes, aws.StringValue(prefix.Prefix))
+		}
+		return fn(page) && !lastPage
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list objects in S3: %w", awsError(err))
+	}
+	return nil
+}
+
+// Copy copies an object server-side within the bucket, keeping its metadata
+func (s *S3BlobStore) Copy(ctx context.Context, sourceKey, destinationKey string) error {
+	_, err := s.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		CopySource: aws.String((&url.URL{Path: s.bucket + "/" + sourceKey}).EscapedPath()),
+		Key:        aws.String(destinationKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy S3 object %s: %w", sourceKey, awsError(err))
+	}
+	return nil
+}
+
+// Delete deletes an object
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete S3 object %s: %w", key, awsError(err))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects how archived weather data is encoded in S3
+type Compression string
+
+const (
+	// CompressionNone stores plain JSON objects with a .json suffix
+	CompressionNone Compression = "none"
+	// CompressionGzip stores gzip objects with a .json.gz suffix and Content-Encoding gzip
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd stores zstd objects with a .json.zst suffix and Content-Encoding zstd
+	CompressionZstd Compression = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// ParseCompression validates a compression name
+func ParseCompression(name string) (Compression, error) {
+	switch compression := Compression(strings.ToLower(strings.TrimSpace(name))); compression {
+	case "", CompressionNone:
+		return CompressionNone, nil
+	case CompressionGzip, CompressionZstd:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("unsupported S3 compression: %s", name)
+	}
+}
+
+// CompressionFromKey infers the compression of an object from its key suffix
+func CompressionFromKey(key string) Compression {
+	switch {
+	case strings.HasSuffix(key, ".gz"):
+		return CompressionGzip
+	case strings.HasSuffix(key, ".zst"):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// KeySuffix returns the suffix appended after .json
+func (c Compression) KeySuffix() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// ContentEncoding returns the Content-Encoding header value, empty for plain objects
+func (c Compression) ContentEncoding() string {
+	if c == CompressionGzip || c == CompressionZstd {
+		return string(c)
+	}
+	return ""
+}
+
+// Compress encodes data with the compression
+func (c Compression) Compress(data []byte) ([]byte, error) {
+	switch c {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to gzip weather data: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("failed to gzip weather data: %w", err)
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return data, nil
+	}
+}
+
+// Decompress decodes an object body. The format is detected from its magic bytes,
+// so compressed and legacy plain objects can be read regardless of key or headers.
+func Decompress(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 object: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip weather data: %w", err)
+		}
+		defer reader.Close()
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to gunzip weather data: %w", err)
+		}
+		return decoded, nil
+	case bytes.HasPrefix(data, zstdMagic):
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		decoded, err := zstdDecoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress zstd weather data: %w", err)
+		}
+		return decoded, nil
+	default:
+		return data, nil
+	}
+}
+
+// initZstd lazily creates the shared zstd encoder and decoder, which are safe for concurrent use
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	if zstdErr != nil {
+		return fmt.Errorf("failed to initialize zstd: %w", zstdErr)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/schema"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// DefaultIteratorConcurrency bounds concurrent GetObject calls when none is configured
+const DefaultIteratorConcurrency = 8
+
+// legacyKeySlack widens legacy key filtering, whose IDs embed the collection time
+// rather than the observation time
+const legacyKeySlack = 3 * time.Hour
+
+// WeatherDataQuery selects archived weather data by city and observation time
+type WeatherDataQuery struct {
+	// City restricts results to one city; empty means all cities
+	City string
+	// Start and End bound the UTC observation time, inclusive
+	Start time.Time
+	End   time.Time
+	// Concurrency bounds concurrent GetObject calls
+	Concurrency int
+	// StartAfter resumes iteration after this key; keys are visited in ascending order
+	StartAfter string
+}
+
+// WeatherDataIterator lazily streams archived weather data in key order
+type WeatherDataIterator struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  chan *pendingObject
+
+	key        string
+	current    *models.S3WeatherData
+	err        error
+	closed     bool
+	seen       map[string]bool
+	unreadable []string
+}
+
+type pendingObject struct {
+	key  string
+	done chan fetchedObject
+}
+
+type fetchedObject struct {
+	data       *models.S3WeatherData
+	err        error
+	unreadable bool
+}
+
+// IterateWeatherData pages through every archived object matching the query. Listing is
+// translated to the narrowest prefixes each key layout allows, and objects are fetched
+// ahead of the consumer with bounded concurrency. A record stored under both layouts is
+// returned once.
+func (h *S3Handler) IterateWeatherData(ctx context.Context, query WeatherDataQuery) *WeatherDataIterator {
+	concurrency := query.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultIteratorConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	it := &WeatherDataIterator{
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  make(chan *pendingObject, concurrency),
+		seen:   make(map[string]bool),
+	}
+
+	go h.produce(ctx, query, concurrency, it.queue)
+
+	return it
+}
+
+// Next advances to the next matching object, returning false when done or on error
+func (it *WeatherDataIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for {
+		var pending *pendingObject
+		var ok bool
+		select {
+		case pending, ok = <-it.queue:
+		case <-it.ctx.Done():
+			it.err = it.ctx.Err()
+			return false
+		}
+		if !ok {
+			return false
+		}
+
+		result := <-pending.done
+		if result.err != nil {
+			it.err = result.err
+			it.cancel()
+			return false
+		}
+		if result.unreadable {
+			it.unreadable = append(it.unreadable, pending.key)
+			continue
+		}
+		if result.data == nil || it.seen[result.data.ID] {
+			continue // Filtered out after decoding, or already read under the other key layout
+		}
+		it.seen[result.data.ID] = true
+
+		it.key = pending.key
+		it.current = result.data
+		return true
+	}
+}
+
+// Value returns the current weather data
+func (it *WeatherDataIterator) Value() *models.S3WeatherData {
+	return it.current
+}
+
+// Key returns the S3 key of the current weather data
+func (it *WeatherDataIterator) Key() string {
+	return it.key
+}
+
+// Err returns the first error encountered while listing or fetching
+func (it *WeatherDataIterator) Err() error {
+	if it.closed {
+		return nil
+	}
+	return it.err
+}
+
+// Unreadable returns the keys skipped so far because their schema couldn't be migrated. Callers
+// report them, since the results are missing those records.
+func (it *WeatherDataIterator) Unreadable() []string {
+	return it.unreadable
+}
+
+// Close stops background listing and fetching
+func (it *WeatherDataIterator) Close() {
+	it.closed = true
+	it.cancel()
+}
+
+// produce lists matching keys and starts bounded fetches, queueing them in key order
+func (h *S3Handler) produce(ctx context.Context, query WeatherDataQuery, concurrency int, queue chan<- *pendingObject) {
+	defer close(queue)
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	enqueue := func(pending *pendingObject) bool {
+		select {
+		case queue <- pending:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	prefixes, err := h.queryPrefixes(ctx, query)
+	if err != nil {
+		pending := &pendingObject{done: make(chan fetchedObject, 1)}
+		pending.done <- fetchedObject{err: err}
+		enqueue(pending)
+		return
+	}
+
+	for _, prefix := range prefixes {
+		var pageErr error
+		err := h.store.List(ctx, storage.ListOptions{
+			Prefix:     prefix,
+			StartAfter: query.StartAfter,
+		}, func(page storage.ListPage) bool {
+			for _, object := range page.Objects {
+				key := object.Key
+				if !h.keyMayMatch(key, query) {
+					continue
+				}
+
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					pageErr = ctx.Err()
+					return false
+				}
+
+				pending := &pendingObject{key: key, done: make(chan fetchedObject, 1)}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+					pending.done <- h.fetchMatching(ctx, key, query)
+				}()
+
+				if !enqueue(pending) {
+					pageErr = ctx.Err()
+					return false
+				}
+			}
+			return true
+		})
+		if err == nil {
+			err = pageErr
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				pending := &pendingObject{done: make(chan fetchedObject, 1)}
+				pending.done <- fetchedObject{err: err}
+				enqueue(pending)
+			}
+			return
+		}
+	}
+}
+
+// fetchMatching downloads an object, returning no data when it falls outside the query
+func (h *S3Handler) fetchMatching(ctx context.Context, key string, query WeatherDataQuery) fetchedObject {
+	data, err := h.getWeatherData(ctx, key)
+	if err != nil {
+		var migrationErr *schema.MigrationError
+		if errors.As(err, &migrationErr) {
+			return fetchedObject{unreadable: true} // Counted by getWeatherData and reported by the iterator
+		}
+		return fetchedObject{err: err}
+	}
+
+	if query.City != "" && !strings.EqualFold(data.CityName, query.City) {
+		return fetchedObject{}
+	}
+	observed := ObservationTime(data)
+	if (!query.Start.IsZero() && observed.Before(query.Start)) || (!query.End.IsZero() && observed.After(query.End)) {
+		return fetchedObject{}
+	}
+
+	return fetchedObject{data: data}
+}
+
+// queryPrefixes translates a query into listing prefixes. Both key layouts are listed, whichever
+// is configured, so objects a layout migration hasn't moved yet are still read; duplicates left
+// by a migration are dropped by the iterator. Legacy prefixes sort before hive ones, keeping
+// keys in ascending order across prefixes.
+func (h *S3Handler) queryPrefixes(ctx context.Context, query WeatherDataQuery) ([]string, error) {
+	years, cities, err := h.listPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if query.City != "" {
+		cities = []string{partitionValue(query.City)}
+	}
+
+	var prefixes []string
+	if query.Start.IsZero() || query.End.IsZero() {
+		for _, year := range years {
+			prefixes = append(prefixes, fmt.Sprintf("%s/%s/", h.keyPrefix, year))
+		}
+		for _, city := range cities {
+			prefixes = append(prefixes, fmt.Sprintf("%s/city=%s/", h.keyPrefix, city))
+		}
+		return prefixes, nil
+	}
+
+	start, end := query.Start.UTC(), query.End.UTC()
+	hasYear := make(map[string]bool, len(years))
+	for _, year := range years {
+		hasYear[year] = true
+	}
+	// Legacy prefixes follow the collection date, so include a day either side
+	for day := start.Add(-24 * time.Hour).Truncate(24 * time.Hour); !day.After(end.Add(24 * time.Hour)); day = day.Add(24 * time.Hour) {
+		if hasYear[day.Format("2006")] {
+			prefixes = append(prefixes, KeyLayoutLegacy.DayPrefix(h.keyPrefix, "", day))
+		}
+	}
+	for _, city := range cities {
+		prefixes = append(prefixes, hivePrefixes(h.keyPrefix, city, start, end)...)
+	}
+	return prefixes, nil
+}
+
+// hivePrefixes covers [start, end] with whole-day prefixes, using hour prefixes for partial days
+func hivePrefixes(keyPrefix, city string, start, end time.Time) []string {
+	var prefixes []string
+	for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.Add(24 * time.Hour) {
+		dayPrefix := KeyLayoutHive.DayPrefix(keyPrefix, city, day)
+		if !day.Before(start) && !day.Add(24*time.Hour-time.Nanosecond).After(end) {
+			prefixes = append(prefixes, dayPrefix)
+			continue
+		}
+		for hour := day; hour.Before(day.Add(24 * time.Hour)); hour = hour.Add(time.Hour) {
+			if hour.Add(time.Hour).After(start) && !hour.After(end) {
+				prefixes = append(prefixes, fmt.Sprintf("%shour=%s/", dayPrefix, hour.Format("15")))
+			}
+		}
+	}
+	return prefixes
+}
+
+// listPartitions returns the legacy year folders and hive city partition values under the
+// archive root, each in ascending order
+func (h *S3Handler) listPartitions(ctx context.Context) (years, cities []string, err error) {
+	root := h.keyPrefix + "/"
+	err = h.store.List(ctx, storage.ListOptions{
+		Prefix:    root,
+		Delimiter: "/",
+	}, func(page storage.ListPage) bool {
+		for _, prefix := range page.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(prefix, root), "/")
+			if city, ok := strings.CutPrefix(name, "city="); ok {
+				cities = append(cities, city)
+			} else {
+				years = append(years, name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list archive partitions in S3: %w", err)
+	}
+
+	return years, cities, nil
+}
+
+// keyMayMatch skips legacy objects whose ID (<city>-<unix collection time>) rules them out,
+// avoiding a GetObject call. Hive keys are already narrowed by prefix.
+func (h *S3Handler) keyMayMatch(key string, query WeatherDataQuery) bool {
+	if strings.Contains(key, "/city=") {
+		return true
+	}
+
+	id := strings.TrimSuffix(path.Base(key), ".json"+CompressionFromKey(key).KeySuffix())
+	sep := strings.LastIndex(id, "-")
+	if sep < 0 {
+		return true
+	}
+	unix, err := strconv.ParseInt(id[sep+1:], 10, 64)
+	if err != nil {
+		return true
+	}
+
+	if query.City != "" && !strings.EqualFold(id[:sep], query.City) {
+		return false
+	}
+	collected := time.Unix(unix, 0)
+	if !query.Start.IsZero() && collected.Before(query.Start.Add(-legacyKeySlack)) {
+		return false
+	}
+	if !query.End.IsZero() && collected.After(query.End.Add(legacyKeySlack)) {
+		return false
+	}
+	return true
+}
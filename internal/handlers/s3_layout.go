@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weather-lambda/internal/models"
+)
+
+// KeyLayout selects how archived weather data is keyed in S3
+type KeyLayout string
+
+const (
+	// KeyLayoutLegacy keys objects as <prefix>/<yyyy>/<MM-dd>/<id>.json using the collection clock
+	KeyLayoutLegacy KeyLayout = "legacy"
+	// KeyLayoutHive keys objects as <prefix>/city=<city>/year=<yyyy>/month=<MM>/day=<dd>/hour=<HH>/<id>.json
+	// using the UTC observation time, so Athena and Glue can prune partitions
+	KeyLayoutHive KeyLayout = "hive"
+)
+
+// DefaultKeyPrefix is the top-level prefix for archived weather data
+const DefaultKeyPrefix = "weather-data"
+
+// ParseKeyLayout validates a key layout name
+func ParseKeyLayout(name string) (KeyLayout, error) {
+	switch layout := KeyLayout(strings.ToLower(strings.TrimSpace(name))); layout {
+	case KeyLayoutLegacy, KeyLayoutHive:
+		return layout, nil
+	default:
+		return "", fmt.Errorf("unsupported S3 key layout: %s", name)
+	}
+}
+
+// ObjectKey returns the S3 key for weather data under prefix
+func (l KeyLayout) ObjectKey(prefix string, data *models.S3WeatherData) string {
+	if l == KeyLayoutHive {
+		observed := ObservationTime(data)
+		return fmt.Sprintf("%s/city=%s/year=%s/month=%s/day=%s/hour=%s/%s.json",
+			prefix,
+			partitionValue(data.CityName),
+			observed.Format("2006"),
+			observed.Format("01"),
+			observed.Format("02"),
+			observed.Format("15"),
+			data.ID,
+		)
+	}
+
+	created := data.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+	return fmt.Sprintf("%s/%s/%s/%s.json",
+		prefix,
+		created.Format("2006"),
+		created.Format("01-02"),
+		data.ID,
+	)
+}
+
+// DayPrefix returns the key prefix holding a day's objects. The hive layout narrows it to
+// the city and UTC day; the legacy layout only partitions by collection date.
+func (l KeyLayout) DayPrefix(prefix, city string, day time.Time) string {
+	if l == KeyLayoutHive {
+		day = day.UTC()
+		return fmt.Sprintf("%s/city=%s/year=%s/month=%s/day=%s/",
+			prefix,
+			partitionValue(city),
+			day.Format("2006"),
+			day.Format("01"),
+			day.Format("02"),
+		)
+	}
+
+	return fmt.Sprintf("%s/%s/%s/", prefix, day.Format("2006"), day.Format("01-02"))
+}
+
+// ObservationTime returns the UTC time the provider observed the weather,
+// falling back to the record timestamp when the raw response has no dt
+func ObservationTime(data *models.S3WeatherData) time.Time {
+	if data.RawResponse.Dt > 0 {
+		return time.Unix(data.RawResponse.Dt, 0).UTC()
+	}
+	if ts, err := time.Parse(time.RFC3339, data.Timestamp); err == nil {
+		return ts.UTC()
+	}
+	return data.CreatedAt.UTC()
+}
+
+// partitionValue makes a city name safe to use as a single key segment
+func partitionValue(value string) string {
+	return strings.NewReplacer("/", "-", "=", "-").Replace(strings.TrimSpace(value))
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/weather-lambda/internal/metrics"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/schema"
+)
+
+// UnmigratableRecordsMetric counts stored payloads that could not be migrated to the current schema
+const UnmigratableRecordsMetric = "UnmigratableRecords"
+
+// decodeWeatherRecord migrates and decodes a DynamoDB item, counting items that can't be read
+func decodeWeatherRecord(item map[string]*dynamodb.AttributeValue) (*models.WeatherRecord, error) {
+	var record models.WeatherRecord
+	if err := schema.DecodeItem(item, &record); err != nil {
+		var id string
+		if av, ok := item["id"]; ok {
+			id = aws.StringValue(av.S)
+		}
+		reportUnmigratable("dynamodb", id, err)
+		return nil, err
+	}
+	return &record, nil
+}
+
+// reportUnmigratable logs a payload that failed migration and emits the metric
+func reportUnmigratable(source, id string, err error) {
+	log.Printf("Skipping unreadable %s record %s: %v", source, id, err)
+	metrics.Count(UnmigratableRecordsMetric, 1, map[string]string{"Source": source})
+}
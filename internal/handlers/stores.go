@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/storage"
+)
+
+var _ storage.RecordStore = (*DynamoDBHandler)(nil)
+
+// Stores bundles the record store and archive selected by the storage backend
+type Stores struct {
+	Records storage.RecordStore
+	// Archive is nil when the AWS backend has no bucket configured
+	Archive *S3Handler
+	// DynamoDB is only set by the AWS backend, for features that need the table itself
+	DynamoDB *DynamoDBHandler
+	// Summaries holds daily rollups; the AWS backend keeps them in the records table
+	Summaries storage.SummaryStore
+	// Latest serves each city's current reading; the AWS backend keeps a snapshot item per city
+	Latest storage.LatestStore
+
+	closers []func() error
+}
+
+// Close releases resources held by the backend, such as the SQLite database
+func (s *Stores) Close() error {
+	var firstErr error
+	for _, closer := range s.closers {
+		if err := closer(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ArchiveOptions maps the archive settings to S3Handler options
+func ArchiveOptions(archiveCfg config.ArchiveConfig) ([]S3Option, error) {
+	keyLayout, err := ParseKeyLayout(archiveCfg.KeyLayout)
+	if err != nil {
+		return nil, err
+	}
+	compression, err := ParseCompression(archiveCfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	return []S3Option{
+		WithKeyLayout(keyLayout),
+		WithKeyPrefix(archiveCfg.KeyPrefix),
+		WithCompression(compression),
+	}, nil
+}
+
+// NewStores creates the stores for the configured backend. Only the AWS backend
+// creates a session and needs credentials.
+func NewStores(cfg *config.Config, storageCfg config.StorageConfig, opts ...S3Option) (*Stores, error) {
+	switch storageCfg.Backend {
+	case config.StorageAWS:
+		sess, err := session.NewSession(&aws.Config{
+			Region: aws.String(cfg.AWS.Region),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AWS session: %w", err)
+		}
+
+		dynamoDBHandler, err := NewDynamoDBHandler(cfg, sess)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create DynamoDB handler: %w", err)
+		}
+		stores := &Stores{Records: dynamoDBHandler, DynamoDB: dynamoDBHandler, Summaries: dynamoDBHandler, Latest: dynamoDBHandler}
+		if cfg.AWS.S3Bucket != "" {
+			stores.Archive = NewS3Handler(cfg, sess, opts...)
+		}
+		return stores, nil
+	case config.StorageMemory:
+		records := storage.NewMemoryRecordStore()
+		archive := NewS3HandlerFromStore(storage.NewMemoryBlobStore(), opts...)
+		return &Stores{
+			Records:   records,
+			Archive:   archive,
+			Summaries: NewS3SummaryStore(archive, DefaultSummaryPrefix),
+			Latest:    storage.NewRecordLatestStore(records),
+		}, nil
+	case config.StorageLocal:
+		records, err := storage.NewLocalRecordStore(filepath.Join(storageCfg.Dir, "records"))
+		if err != nil {
+			return nil, err
+		}
+		blobs, err := storage.NewLocalBlobStore(filepath.Join(storageCfg.Dir, "archive"))
+		if err != nil {
+			return nil, err
+		}
+		archive := NewS3HandlerFromStore(blobs, opts...)
+		return &Stores{
+			Records:   records,
+			Archive:   archive,
+			Summaries: NewS3SummaryStore(archive, DefaultSummaryPrefix),
+			Latest:    storage.NewRecordLatestStore(records),
+		}, nil
+	case config.StorageSQLite:
+		records, err := storage.NewSQLiteRecordStore(storageCfg.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		blobs, err := storage.NewLocalBlobStore(filepath.Join(storageCfg.Dir, "archive"))
+		if err != nil {
+			records.Close()
+			return nil, err
+		}
+
+		ctx, stopPurge := context.WithCancel(context.Background())
+		if storageCfg.PurgeInterval > 0 {
+			records.StartPurge(ctx, storageCfg.PurgeInterval)
+		}
+		archive := NewS3HandlerFromStore(blobs, opts...)
+		return &Stores{
+			Records:   records,
+			Archive:   archive,
+			Summaries: NewS3SummaryStore(archive, DefaultSummaryPrefix),
+			Latest:    storage.NewRecordLatestStore(records),
+			closers: []func() error{
+				func() error { stopPurge(); return nil },
+				records.Close,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported STORAGE_BACKEND: %s", storageCfg.Backend)
+	}
+}
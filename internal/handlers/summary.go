@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// Summary items share the records table, keyed apart from weather records. They carry no
+// cityName or ttl, so they stay out of the city index and never expire.
+const summaryIDPrefix = "daily-summary#"
+
+// DefaultSummaryPrefix is where S3SummaryStore keeps summaries in the archive bucket
+const DefaultSummaryPrefix = "summaries/daily"
+
+const summaryDateLayout = "2006-01-02"
+
+var (
+	_ storage.SummaryStore = (*DynamoDBHandler)(nil)
+	_ storage.SummaryStore = (*S3SummaryStore)(nil)
+)
+
+// PutDailySummary stores a summary, replacing any earlier one for the same city and day
+func (h *DynamoDBHandler) PutDailySummary(ctx context.Context, summary *models.DailySummary) error {
+	item, err := dynamodbattribute.MarshalMap(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal daily summary: %w", err)
+	}
+	item["id"] = &dynamodb.AttributeValue{S: aws.String(summaryID(summary.City))}
+
+	_, err = h.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(h.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put daily summary to DynamoDB: %w", awsError(err))
+	}
+	return nil
+}
+
+// GetDailySummaries returns a city's summaries for the UTC days from start to end, oldest first
+func (h *DynamoDBHandler) GetDailySummaries(ctx context.Context, city string, start, end time.Time) ([]models.DailySummary, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(h.tableName),
+		KeyConditionExpression: aws.String("id = :id AND #ts BETWEEN :start AND :end"),
+		ExpressionAttributeNames: map[string]*string{
+			"#ts": aws.String("timestamp"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":id":    {S: aws.String(summaryID(city))},
+			":start": {S: aws.String(start.UTC().Format(summaryDateLayout))},
+			":end":   {S: aws.String(end.UTC().Format(summaryDateLayout))},
+		},
+		ScanIndexForward: aws.Bool(true),
+	}
+
+	var summaries []models.DailySummary
+	var decodeErr error
+	err := h.client.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var decoded []models.DailySummary
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &decoded); decodeErr != nil {
+			return false
+		}
+		summaries = append(summaries, decoded...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily summaries: %w", awsError(err))
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode daily summaries: %w", decodeErr)
+	}
+	return summaries, nil
+}
+
+func summaryID(city string) string {
+	return summaryIDPrefix + strings.ToLower(city)
+}
+
+// S3SummaryStore keeps daily summaries as JSON objects in the archive bucket, for backends
+// without DynamoDB
+type S3SummaryStore struct {
+	handler *S3Handler
+	prefix  string
+}
+
+// NewS3SummaryStore creates a summary store under prefix in the handler's bucket
+func NewS3SummaryStore(handler *S3Handler, prefix string) *S3SummaryStore {
+	return &S3SummaryStore{
+		handler: handler,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// PutDailySummary stores a summary, replacing any earlier one for the same city and day
+func (s *S3SummaryStore) PutDailySummary(ctx context.Context, summary *models.DailySummary) error {
+	jsonData, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal daily summary: %w", err)
+	}
+	if err := s.handler.StoreObject(path.Join(s.cityPrefix(summary.City), summary.Date+".json"), jsonData, "application/json"); err != nil {
+		return fmt.Errorf("failed to upload daily summary: %w", err)
+	}
+	return nil
+}
+
+// GetDailySummaries returns a city's summaries for the UTC days from start to end, oldest first
+func (s *S3SummaryStore) GetDailySummaries(ctx context.Context, city string, start, end time.Time) ([]models.DailySummary, error) {
+	first, last := start.UTC().Format(summaryDateLayout), end.UTC().Format(summaryDateLayout)
+
+	var keys []string
+	err := s.handler.WalkWeatherData(s.cityPrefix(city)+"/", func(object storage.Object) bool {
+		date := strings.TrimSuffix(path.Base(object.Key), ".json")
+		if date >= first && date <= last {
+			keys = append(keys, object.Key)
+		}
+		return date <= last // Keys are listed in date order
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily summaries: %w", err)
+	}
+
+	summaries := make([]models.DailySummary, 0, len(keys))
+	for _, key := range keys {
+		body, err := s.handler.GetObject(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get daily summary %s: %w", key, err)
+		}
+		var summary models.DailySummary
+		if err := json.Unmarshal(body, &summary); err != nil {
+			return nil, fmt.Errorf("failed to decode daily summary %s: %w", key, err)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func (s *S3SummaryStore) cityPrefix(city string) string {
+	return path.Join(s.prefix, "city="+strings.ToLower(city))
+}
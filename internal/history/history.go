@@ -0,0 +1,251 @@
+// Package history serves weather history queries across DynamoDB, daily summaries and the S3 archive.
+package history
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// Record sources reported in query results
+const (
+	SourceDynamoDB  = "dynamodb"
+	SourceArchive   = "s3"
+	SourceSummaries = "summaries"
+)
+
+// archiveOverlap extends archive reads past the retention boundary, since local record stores
+// and backfilled DynamoDB records are ranged by collection time while the archive is keyed by
+// observation time. Duplicates are dropped by ID.
+const archiveOverlap = time.Hour
+
+// Result holds merged history records, oldest first, and daily summaries for the days
+// older than the retention window
+type Result struct {
+	Records   []models.WeatherRecord
+	Summaries []models.DailySummary
+	Sources   []string
+	// Unreadable counts archived objects left out because their schema couldn't be migrated
+	Unreadable int
+	// MissingDays lists the days (2006-01-02) older than the retention window that have no
+	// daily summary and no archive to read instead
+	MissingDays []string
+}
+
+// Service queries recent records from the record store and older ones from the archive
+type Service struct {
+	records            storage.RecordStore
+	s3Handler          *handlers.S3Handler
+	summaries          storage.SummaryStore
+	retention          func(city string) time.Duration
+	archiveConcurrency int
+	now                func() time.Time
+}
+
+// NewService creates a history service. retention returns how long a city's records stay in
+// the record store, zero meaning forever. s3Handler may be nil to disable the archive fallback.
+func NewService(records storage.RecordStore, s3Handler *handlers.S3Handler, retention func(city string) time.Duration, archiveConcurrency int) *Service {
+	return &Service{
+		records:            records,
+		s3Handler:          s3Handler,
+		retention:          retention,
+		archiveConcurrency: archiveConcurrency,
+		now:                time.Now,
+	}
+}
+
+// SetSummaries serves days older than the retention window from daily summaries. The
+// archive then only fills in the days before the earliest summary and days without one.
+func (s *Service) SetSummaries(summaries storage.SummaryStore) {
+	s.summaries = summaries
+}
+
+// FixedRetention applies the same retention to every city
+func FixedRetention(retention time.Duration) func(city string) time.Duration {
+	return func(string) time.Duration { return retention }
+}
+
+// RetentionBoundary returns the oldest time still served from DynamoDB for a city,
+// or the zero time when its records never expire
+func (s *Service) RetentionBoundary(cityName string) time.Time {
+	retention := s.retention(cityName)
+	if retention <= 0 {
+		return time.Time{}
+	}
+	return s.now().Add(-retention)
+}
+
+// Query returns a city's records between startTime and endTime. Ranges older than the
+// DynamoDB retention window are served from daily summaries when configured, and otherwise
+// read from the archive and merged transparently.
+func (s *Service) Query(ctx context.Context, cityName string, startTime, endTime time.Time) (*Result, error) {
+	boundary := s.RetentionBoundary(cityName)
+	result := &Result{}
+	seen := make(map[string]bool)
+
+	add := func(record models.WeatherRecord) {
+		if seen[record.ID] {
+			return
+		}
+		seen[record.ID] = true
+		result.Records = append(result.Records, record)
+	}
+
+	archiveEnd := boundary.Add(archiveOverlap)
+	var gaps []timeRange
+	if s.summaries != nil && startTime.Before(boundary) {
+		// Every day that starts before the boundary has lost raw records, so its summary is served
+		lastDay := boundary.UTC().Truncate(24 * time.Hour)
+		if endTime.Before(lastDay) {
+			lastDay = endTime
+		}
+		summaries, err := s.summaries.GetDailySummaries(ctx, cityName, startTime, lastDay)
+		if err != nil {
+			return nil, err
+		}
+		if len(summaries) > 0 {
+			result.Summaries = summaries
+			result.Sources = append(result.Sources, SourceSummaries)
+			if first, err := time.Parse("2006-01-02", summaries[0].Date); err == nil && first.Before(archiveEnd) {
+				archiveEnd = first
+			}
+		}
+		gaps = summaryGaps(summaries, startTime.UTC().Truncate(24*time.Hour), lastDay)
+	}
+	if endTime.Before(archiveEnd) {
+		archiveEnd = endTime
+	}
+
+	if s.s3Handler != nil {
+		// The archive covers the days before the first summary and any day after it missing
+		// one, such as a day the rollup didn't run
+		ranges := []timeRange{{startTime, archiveEnd}}
+		for _, gap := range gaps {
+			// Gaps up to archiveEnd were read with the days before the first summary
+			ranges = append(ranges, gap.clip(maxTime(startTime, archiveEnd), minTime(endTime, boundary.Add(archiveOverlap))))
+		}
+		read := false
+		for _, r := range ranges {
+			if !r.start.Before(r.end) {
+				continue
+			}
+			unreadable, err := s.readArchive(ctx, cityName, r, add)
+			if err != nil {
+				return nil, err
+			}
+			result.Unreadable += unreadable
+			read = true
+		}
+		if read {
+			result.Sources = append(result.Sources, SourceArchive)
+		}
+	} else {
+		// Without an archive, days without a summary can't be served, so report them rather
+		// than return a range that looks complete
+		for _, gap := range gaps {
+			for day := gap.start; day.Before(gap.end); day = day.AddDate(0, 0, 1) {
+				result.MissingDays = append(result.MissingDays, day.Format("2006-01-02"))
+			}
+		}
+	}
+
+	olderSources := s.s3Handler != nil || s.summaries != nil
+	if !olderSources || endTime.After(boundary) {
+		recordsStart := startTime
+		if olderSources && recordsStart.Before(boundary) {
+			recordsStart = boundary
+		}
+
+		records, err := s.records.GetWeatherHistory(ctx, cityName, recordsStart, endTime)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			add(record)
+		}
+		result.Sources = append(result.Sources, SourceDynamoDB)
+	}
+
+	// Sort records by observation time (oldest first), as the stores order their ranges
+	sort.SliceStable(result.Records, func(i, j int) bool {
+		return result.Records[i].ObservationTime().Before(result.Records[j].ObservationTime())
+	})
+
+	return result, nil
+}
+
+// readArchive adds the city's archived records in r, returning how many objects were unreadable
+func (s *Service) readArchive(ctx context.Context, cityName string, r timeRange, add func(models.WeatherRecord)) (int, error) {
+	it := s.s3Handler.IterateWeatherData(ctx, handlers.WeatherDataQuery{
+		City:        cityName,
+		Start:       r.start,
+		End:         r.end,
+		Concurrency: s.archiveConcurrency,
+	})
+	defer it.Close()
+	for it.Next() {
+		add(it.Value().WeatherRecord)
+	}
+	if err := it.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read weather archive: %w", err)
+	}
+	unreadable := it.Unreadable()
+	if len(unreadable) > 0 {
+		log.Printf("History for %s is missing %d unreadable archived objects: %v", cityName, len(unreadable), unreadable)
+	}
+	return len(unreadable), nil
+}
+
+// timeRange is a span of time from start up to end
+type timeRange struct {
+	start, end time.Time
+}
+
+// clip narrows r to [start, end]
+func (r timeRange) clip(start, end time.Time) timeRange {
+	if r.start.Before(start) {
+		r.start = start
+	}
+	return timeRange{r.start, minTime(r.end, end)}
+}
+
+// summaryGaps returns the runs of days from firstDay up to and including lastDay that have no summary
+func summaryGaps(summaries []models.DailySummary, firstDay, lastDay time.Time) []timeRange {
+	have := make(map[string]bool, len(summaries))
+	for _, summary := range summaries {
+		have[summary.Date] = true
+	}
+
+	var gaps []timeRange
+	for day := firstDay; !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+		if have[day.Format("2006-01-02")] {
+			continue
+		}
+		if n := len(gaps); n > 0 && gaps[n-1].end.Equal(day) {
+			gaps[n-1].end = day.AddDate(0, 0, 1)
+			continue
+		}
+		gaps = append(gaps, timeRange{day, day.AddDate(0, 0, 1)})
+	}
+	return gaps
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// DefaultCheckpointPrefix is the S3 prefix holding job checkpoints
+const DefaultCheckpointPrefix = "checkpoints"
+
+// Checkpoint records how far a resumable job has progressed through the archive
+type Checkpoint struct {
+	JobID string `json:"jobId"`
+	// LastKey is the last archive key fully handled; a resumed job starts after it
+	LastKey   string    `json:"lastKey,omitempty"`
+	Processed int       `json:"processed"`
+	Written   int       `json:"written"`
+	Skipped   int       `json:"skipped"`
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Unreadable counts archived objects skipped because their schema couldn't be migrated
+	Unreadable int `json:"unreadable"`
+}
+
+// CheckpointStore persists checkpoints as JSON objects in S3
+type CheckpointStore struct {
+	s3Handler *handlers.S3Handler
+	prefix    string
+	kind      string
+}
+
+// NewCheckpointStore creates a store for one kind of job under prefix
+func NewCheckpointStore(s3Handler *handlers.S3Handler, prefix, kind string) *CheckpointStore {
+	return &CheckpointStore{
+		s3Handler: s3Handler,
+		prefix:    strings.TrimSuffix(prefix, "/"),
+		kind:      kind,
+	}
+}
+
+// Key returns the S3 key of a job's checkpoint
+func (s *CheckpointStore) Key(jobID string) string {
+	return fmt.Sprintf("%s/%s/%s.json", s.prefix, s.kind, jobID)
+}
+
+// Load returns the saved checkpoint for jobID, or a fresh one when none exists
+func (s *CheckpointStore) Load(jobID string) (*Checkpoint, error) {
+	body, err := s.s3Handler.GetObject(s.Key(jobID))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return &Checkpoint{JobID: jobID}, nil
+		}
+		return nil, fmt.Errorf("failed to load checkpoint %s: %w", jobID, err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(body, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", jobID, err)
+	}
+
+	return &checkpoint, nil
+}
+
+// Save writes the checkpoint, stamping its update time
+func (s *CheckpointStore) Save(checkpoint *Checkpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+	body, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint %s: %w", checkpoint.JobID, err)
+	}
+
+	return s.s3Handler.StoreObject(s.Key(checkpoint.JobID), body, "application/json")
+}
@@ -0,0 +1,209 @@
+// Package jobs implements batch maintenance actions over the weather archive and records table.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/models"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// CompactionResult summarizes one city's compacted day
+type CompactionResult struct {
+	City          string `json:"city"`
+	Date          string `json:"date"`
+	Key           string `json:"key,omitempty"`
+	Rows          int    `json:"rows"`
+	SourceObjects int    `json:"sourceObjects"`
+	Deleted       int    `json:"deleted"`
+	// Unreadable counts objects left out of the Parquet file, and never deleted, because
+	// their schema couldn't be migrated
+	Unreadable int `json:"unreadable,omitempty"`
+}
+
+// Compactor rewrites a day's per-reading JSON objects into one Parquet file per city
+type Compactor struct {
+	s3Handler            *handlers.S3Handler
+	curatedPrefix        string
+	allowDeleteOriginals bool
+}
+
+// NewCompactor creates a compactor writing under curatedPrefix. Deleting the compacted
+// originals is refused unless allowDeleteOriginals is set: IterateWeatherData, which serves
+// history, rehydrate and reprocess, doesn't read curated Parquet files.
+func NewCompactor(s3Handler *handlers.S3Handler, curatedPrefix string, allowDeleteOriginals bool) *Compactor {
+	return &Compactor{
+		s3Handler:            s3Handler,
+		curatedPrefix:        strings.TrimSuffix(curatedPrefix, "/"),
+		allowDeleteOriginals: allowDeleteOriginals,
+	}
+}
+
+// CuratedKey returns the Parquet key for a city and UTC day
+func (c *Compactor) CuratedKey(city string, day time.Time) string {
+	day = day.UTC()
+	return fmt.Sprintf("%s/city=%s/year=%s/month=%s/day=%s/%s-%s.parquet",
+		c.curatedPrefix,
+		strings.ReplaceAll(city, "/", "-"),
+		day.Format("2006"),
+		day.Format("01"),
+		day.Format("02"),
+		strings.ReplaceAll(city, "/", "-"),
+		day.Format("2006-01-02"),
+	)
+}
+
+// CompactDay writes the city's observations for the UTC day into a Parquet file, reads it
+// back to verify the row count and, when deleteOriginals is set, removes the source objects.
+// deleteOriginals fails with a validation error unless the compactor allows it.
+func (c *Compactor) CompactDay(ctx context.Context, city string, day time.Time, deleteOriginals bool) (*CompactionResult, error) {
+	if deleteOriginals && !c.allowDeleteOriginals {
+		return nil, errs.Invalid("deleteOriginals", "deleting compacted originals is disabled because the archive readers don't read Parquet files; set COMPACT_ALLOW_DELETE_ORIGINALS=true to allow it")
+	}
+
+	day = day.UTC().Truncate(24 * time.Hour)
+	result := &CompactionResult{
+		City: city,
+		Date: day.Format("2006-01-02"),
+	}
+
+	var rows []models.WeatherParquetRow
+	var sourceKeys []string
+	it := c.s3Handler.IterateWeatherData(ctx, handlers.WeatherDataQuery{
+		City:  city,
+		Start: day,
+		End:   day.Add(24*time.Hour - time.Nanosecond),
+	})
+	defer it.Close()
+	for it.Next() {
+		data := it.Value()
+		rows = append(rows, toParquetRow(data, handlers.ObservationTime(data)))
+		sourceKeys = append(sourceKeys, it.Key())
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archived readings: %w", err)
+	}
+	if unreadable := it.Unreadable(); len(unreadable) > 0 {
+		log.Printf("Leaving %d unreadable objects for %s on %s out of the compaction: %v", len(unreadable), city, result.Date, unreadable)
+		result.Unreadable = len(unreadable)
+	}
+
+	result.SourceObjects = len(sourceKeys)
+	if len(rows) == 0 {
+		log.Printf("No observations to compact for %s on %s", city, result.Date)
+		return result, nil
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].ObservedAt < rows[j].ObservedAt
+	})
+
+	body, err := encodeParquet(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Key = c.CuratedKey(city, day)
+	if err := c.s3Handler.StoreObject(result.Key, body, "application/vnd.apache.parquet"); err != nil {
+		return nil, err
+	}
+
+	// Verify what actually landed in S3 before touching the originals
+	written, err := c.s3Handler.GetObject(result.Key)
+	if err != nil {
+		return nil, err
+	}
+	count, err := countParquetRows(written)
+	if err != nil {
+		return nil, err
+	}
+	if count != int64(len(rows)) {
+		return nil, fmt.Errorf("row count mismatch for %s: wrote %d, read back %d", result.Key, len(rows), count)
+	}
+	result.Rows = len(rows)
+
+	if deleteOriginals {
+		for _, key := range sourceKeys {
+			if err := c.s3Handler.DeleteWeatherData(key); err != nil {
+				return result, err
+			}
+			result.Deleted++
+		}
+	}
+
+	return result, nil
+}
+
+func toParquetRow(data *models.S3WeatherData, observed time.Time) models.WeatherParquetRow {
+	raw := data.RawResponse
+	row := models.WeatherParquetRow{
+		ID:            data.ID,
+		CityName:      data.CityName,
+		Country:       data.Country,
+		ObservedAt:    observed.UnixMilli(),
+		CollectedAt:   data.CreatedAt.UTC().UnixMilli(),
+		Latitude:      raw.Coord.Lat,
+		Longitude:     raw.Coord.Lon,
+		Temperature:   data.Temperature,
+		FeelsLike:     raw.Main.FeelsLike,
+		TempMin:       raw.Main.TempMin,
+		TempMax:       raw.Main.TempMax,
+		Pressure:      int32(data.Pressure),
+		Humidity:      int32(data.Humidity),
+		WindSpeed:     data.WindSpeed,
+		WindDeg:       int32(raw.Wind.Deg),
+		Clouds:        int32(raw.Clouds.All),
+		Precipitation: data.Precipitation,
+		Description:   data.Description,
+	}
+	if len(raw.Weather) > 0 {
+		row.Condition = raw.Weather[0].Main
+	}
+	return row
+}
+
+func encodeParquet(rows []models.WeatherParquetRow) ([]byte, error) {
+	var buf bytes.Buffer
+	pw, err := writer.NewParquetWriterFromWriter(&buf, new(models.WeatherParquetRow), 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet writer: %w", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for i := range rows {
+		if err := pw.Write(rows[i]); err != nil {
+			return nil, fmt.Errorf("failed to write parquet row %s: %w", rows[i].ID, err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return nil, fmt.Errorf("failed to finish parquet file: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func countParquetRows(data []byte) (int64, error) {
+	file, err := buffer.NewBufferFile(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open parquet file: %w", err)
+	}
+
+	pr, err := reader.NewParquetReader(file, new(models.WeatherParquetRow), 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read parquet file: %w", err)
+	}
+	defer pr.ReadStop()
+
+	return pr.GetNumRows(), nil
+}
@@ -0,0 +1,155 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/services"
+)
+
+// DefaultRehydrateBatchSize is how many records are written between checkpoints
+const DefaultRehydrateBatchSize = 100
+
+// RehydrateOptions selects the archived readings to load and how their TTL is set
+type RehydrateOptions struct {
+	City  string
+	Start time.Time
+	End   time.Time
+	// BatchSize is the number of records written between checkpoints
+	BatchSize int
+	// TTLDays overrides the derived TTL: negative keeps it, 0 disables expiry
+	// and a positive value expires records that many days from now
+	TTLDays int
+	// Concurrency bounds concurrent archive reads
+	Concurrency int
+}
+
+// Rehydrator rebuilds a records table from the raw responses kept in the S3 archive
+type Rehydrator struct {
+	s3Handler      *handlers.S3Handler
+	dynamoHandler  *handlers.DynamoDBHandler
+	weatherService *services.WeatherService
+	checkpoints    *CheckpointStore
+}
+
+// NewRehydrator creates a rehydrator loading into the table behind dynamoHandler
+func NewRehydrator(s3Handler *handlers.S3Handler, dynamoHandler *handlers.DynamoDBHandler, weatherService *services.WeatherService, checkpoints *CheckpointStore) *Rehydrator {
+	return &Rehydrator{
+		s3Handler:      s3Handler,
+		dynamoHandler:  dynamoHandler,
+		weatherService: weatherService,
+		checkpoints:    checkpoints,
+	}
+}
+
+// RehydrateReport counts the work done by one run. Job holds the totals across every run
+// of the job, as of its last checkpoint.
+type RehydrateReport struct {
+	Resumed    bool        `json:"resumed"`
+	Processed  int         `json:"processed"`
+	Written    int         `json:"written"`
+	Skipped    int         `json:"skipped"`
+	Unreadable int         `json:"unreadable"`
+	Job        *Checkpoint `json:"job"`
+}
+
+// Run loads the archived readings selected by opts, resuming from the job's checkpoint.
+// The checkpoint is saved after every batch so an interrupted run can be restarted with the same jobID.
+func (r *Rehydrator) Run(ctx context.Context, jobID string, opts RehydrateOptions) (*RehydrateReport, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultRehydrateBatchSize
+	}
+
+	checkpoint, err := r.checkpoints.Load(jobID)
+	if err != nil {
+		return nil, err
+	}
+	report := &RehydrateReport{Job: checkpoint}
+	if checkpoint.Done {
+		log.Printf("Rehydrate job %s already completed at %s", jobID, checkpoint.UpdatedAt.Format(time.RFC3339))
+		return report, nil
+	}
+	if checkpoint.LastKey != "" {
+		report.Resumed = true
+		log.Printf("Resuming rehydrate job %s after %s (%d records written)", jobID, checkpoint.LastKey, checkpoint.Written)
+	}
+
+	// The checkpoint's counters only advance when a batch is saved, so they always describe
+	// the objects up to its LastKey and a resumed run doesn't count those again
+	before := *checkpoint
+	var batch []*models.WeatherRecord
+	var lastKey string
+	var it *handlers.WeatherDataIterator
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := r.dynamoHandler.BatchStoreWeatherRecords(ctx, batch); err != nil {
+				return err
+			}
+		}
+		report.Written += len(batch)
+		report.Unreadable = len(it.Unreadable())
+		if lastKey != "" {
+			checkpoint.LastKey = lastKey
+		}
+		checkpoint.Processed = before.Processed + report.Processed
+		checkpoint.Written = before.Written + report.Written
+		checkpoint.Skipped = before.Skipped + report.Skipped
+		checkpoint.Unreadable = before.Unreadable + report.Unreadable
+		batch = batch[:0]
+		return r.checkpoints.Save(checkpoint)
+	}
+
+	it = r.s3Handler.IterateWeatherData(ctx, handlers.WeatherDataQuery{
+		City:        opts.City,
+		Start:       opts.Start,
+		End:         opts.End,
+		Concurrency: opts.Concurrency,
+		StartAfter:  checkpoint.LastKey,
+	})
+	defer it.Close()
+	for it.Next() {
+		data := it.Value()
+		lastKey = it.Key()
+		report.Processed++
+
+		if data.RawResponse.Name == "" && data.RawResponse.Dt == 0 {
+			log.Printf("Skipping %s: archived object has no raw response", lastKey)
+			report.Skipped++
+			continue
+		}
+
+		record := r.weatherService.RederiveWeatherRecord(data)
+		switch {
+		case opts.TTLDays == 0:
+			record.TTL = 0 // DynamoDB ignores TTL values more than five years in the past
+		case opts.TTLDays > 0:
+			record.TTL = time.Now().Add(time.Duration(opts.TTLDays) * 24 * time.Hour).Unix()
+		}
+		batch = append(batch, record)
+
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return report, fmt.Errorf("failed to write batch ending at %s: %w", lastKey, err)
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return report, fmt.Errorf("failed to read weather archive: %w", err)
+	}
+	if unreadable := it.Unreadable(); len(unreadable) > 0 {
+		log.Printf("Could not rehydrate %d unreadable archived objects: %v", len(unreadable), unreadable)
+	}
+
+	checkpoint.Done = true
+	if err := flush(); err != nil {
+		checkpoint.Done = false
+		return report, fmt.Errorf("failed to write final batch: %w", err)
+	}
+
+	return report, nil
+}
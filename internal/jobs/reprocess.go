@@ -0,0 +1,228 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/services"
+)
+
+// DefaultReprocessCheckpointEvery is how many archived objects are handled between checkpoints
+const DefaultReprocessCheckpointEvery = 100
+
+// ReprocessOptions selects the archived readings to re-derive
+type ReprocessOptions struct {
+	// City restricts reprocessing to one city; empty means all cities
+	City string
+	// Start and End bound the observation time; zero values leave the range open
+	Start time.Time
+	End   time.Time
+	// DryRun reports what would change without writing to S3, DynamoDB or the checkpoint
+	DryRun bool
+	// MaxDiffs caps how many record diffs the report keeps; 0 keeps all of them
+	MaxDiffs int
+	// CheckpointEvery is the number of archived objects handled between checkpoints
+	CheckpointEvery int
+	// Concurrency bounds concurrent archive reads
+	Concurrency int
+}
+
+// FieldChange is one record field whose derived value differs
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// RecordDiff lists the changed fields of one archived record
+type RecordDiff struct {
+	Key     string        `json:"key"`
+	ID      string        `json:"id"`
+	Changes []FieldChange `json:"changes"`
+	// DynamoDBUpdated is false when the record has already expired from DynamoDB
+	DynamoDBUpdated bool `json:"dynamoDBUpdated"`
+}
+
+// ReprocessReport summarizes a reprocessing run
+type ReprocessReport struct {
+	DryRun    bool `json:"dryRun"`
+	Processed int  `json:"processed"`
+	Changed   int  `json:"changed"`
+	Unchanged int  `json:"unchanged"`
+	Skipped   int  `json:"skipped"`
+	// Unreadable counts archived objects whose schema couldn't be migrated
+	Unreadable int `json:"unreadable"`
+	// FieldCounts counts changed records per field
+	FieldCounts map[string]int `json:"fieldCounts"`
+	Diffs       []RecordDiff   `json:"diffs,omitempty"`
+	// Truncated is set when more diffs were found than MaxDiffs allows
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Reprocessor re-runs the current conversion over archived raw responses and
+// rewrites records whose derivation changed
+type Reprocessor struct {
+	s3Handler      *handlers.S3Handler
+	dynamoHandler  *handlers.DynamoDBHandler
+	weatherService *services.WeatherService
+	checkpoints    *CheckpointStore
+}
+
+// NewReprocessor creates a reprocessor
+func NewReprocessor(s3Handler *handlers.S3Handler, dynamoHandler *handlers.DynamoDBHandler, weatherService *services.WeatherService, checkpoints *CheckpointStore) *Reprocessor {
+	return &Reprocessor{
+		s3Handler:      s3Handler,
+		dynamoHandler:  dynamoHandler,
+		weatherService: weatherService,
+		checkpoints:    checkpoints,
+	}
+}
+
+// Run diffs every selected archived record against a fresh derivation from its raw response.
+// Changed records are rewritten in place in S3 and replaced in DynamoDB if still present.
+// Real runs resume from the job's checkpoint; dry runs always scan the full selection.
+func (r *Reprocessor) Run(ctx context.Context, jobID string, opts ReprocessOptions) (*ReprocessReport, error) {
+	every := opts.CheckpointEvery
+	if every <= 0 {
+		every = DefaultReprocessCheckpointEvery
+	}
+
+	report := &ReprocessReport{
+		DryRun:      opts.DryRun,
+		FieldCounts: make(map[string]int),
+	}
+
+	checkpoint := &Checkpoint{JobID: jobID}
+	if !opts.DryRun {
+		var err error
+		if checkpoint, err = r.checkpoints.Load(jobID); err != nil {
+			return nil, err
+		}
+		if checkpoint.Done {
+			log.Printf("Reprocess job %s already completed at %s", jobID, checkpoint.UpdatedAt.Format(time.RFC3339))
+			return report, nil
+		}
+		if checkpoint.LastKey != "" {
+			log.Printf("Resuming reprocess job %s after %s", jobID, checkpoint.LastKey)
+		}
+	}
+
+	unreadableBefore := checkpoint.Unreadable
+	it := r.s3Handler.IterateWeatherData(ctx, handlers.WeatherDataQuery{
+		City:        opts.City,
+		Start:       opts.Start,
+		End:         opts.End,
+		Concurrency: opts.Concurrency,
+		StartAfter:  checkpoint.LastKey,
+	})
+	defer it.Close()
+	for it.Next() {
+		key, data := it.Key(), it.Value()
+		report.Processed++
+		checkpoint.Processed++
+
+		if data.RawResponse.Name == "" && data.RawResponse.Dt == 0 {
+			report.Skipped++
+			checkpoint.Skipped++
+		} else {
+			changed, err := r.reprocess(key, data, opts, report)
+			if err != nil {
+				return report, err
+			}
+			if changed {
+				checkpoint.Written++
+			}
+		}
+
+		if !opts.DryRun && report.Processed%every == 0 {
+			checkpoint.LastKey = key
+			checkpoint.Unreadable = unreadableBefore + len(it.Unreadable())
+			if err := r.checkpoints.Save(checkpoint); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return report, fmt.Errorf("failed to read weather archive: %w", err)
+	}
+	if unreadable := it.Unreadable(); len(unreadable) > 0 {
+		log.Printf("Could not reprocess %d unreadable archived objects: %v", len(unreadable), unreadable)
+		report.Unreadable = len(unreadable)
+	}
+	checkpoint.Unreadable = unreadableBefore + report.Unreadable
+
+	if !opts.DryRun {
+		checkpoint.Done = true
+		if err := r.checkpoints.Save(checkpoint); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// reprocess re-derives one archived record and, unless dry-running, writes it back when it changed
+func (r *Reprocessor) reprocess(key string, data *models.S3WeatherData, opts ReprocessOptions, report *ReprocessReport) (bool, error) {
+	record := r.weatherService.RederiveWeatherRecord(data)
+	changes := DiffWeatherRecords(&data.WeatherRecord, record)
+	if len(changes) == 0 {
+		report.Unchanged++
+		return false, nil
+	}
+
+	report.Changed++
+	for _, change := range changes {
+		report.FieldCounts[change.Field]++
+	}
+	diff := RecordDiff{Key: key, ID: record.ID, Changes: changes}
+
+	if !opts.DryRun {
+		updated := &models.S3WeatherData{WeatherRecord: *record, RawResponse: data.RawResponse}
+		if err := r.s3Handler.RewriteWeatherData(key, updated); err != nil {
+			return true, fmt.Errorf("failed to rewrite %s: %w", key, err)
+		}
+
+		replaced, err := r.dynamoHandler.ReplaceWeatherRecord(record)
+		if err != nil {
+			return true, fmt.Errorf("failed to update record %s: %w", record.ID, err)
+		}
+		diff.DynamoDBUpdated = replaced
+	}
+
+	if opts.MaxDiffs > 0 && len(report.Diffs) >= opts.MaxDiffs {
+		report.Truncated = true
+	} else {
+		report.Diffs = append(report.Diffs, diff)
+	}
+	return true, nil
+}
+
+// DiffWeatherRecords compares every WeatherRecord field, naming changes by their JSON attribute
+func DiffWeatherRecords(stored, derived *models.WeatherRecord) []FieldChange {
+	var changes []FieldChange
+	oldValue, newValue := reflect.ValueOf(*stored), reflect.ValueOf(*derived)
+	recordType := oldValue.Type()
+	for i := 0; i < recordType.NumField(); i++ {
+		before, after := oldValue.Field(i).Interface(), newValue.Field(i).Interface()
+		if oldTime, ok := before.(time.Time); ok {
+			if oldTime.Equal(after.(time.Time)) {
+				continue
+			}
+		} else if reflect.DeepEqual(before, after) {
+			continue
+		}
+
+		name := strings.Split(recordType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = recordType.Field(i).Name
+		}
+		changes = append(changes, FieldChange{Field: name, Old: before, New: after})
+	}
+	return changes
+}
@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+// Rollup condenses a day of raw records into a daily summary before the records expire
+type Rollup struct {
+	records   storage.RecordStore
+	summaries storage.SummaryStore
+}
+
+// NewRollup creates a rollup reading from records and writing to summaries
+func NewRollup(records storage.RecordStore, summaries storage.SummaryStore) *Rollup {
+	return &Rollup{records: records, summaries: summaries}
+}
+
+// RollupDay summarizes the city's records observed on the UTC day and stores the summary, replacing
+// any earlier one. It returns nil without storing anything when the day has no records.
+func (r *Rollup) RollupDay(ctx context.Context, city string, day time.Time) (*models.DailySummary, error) {
+	start := day.UTC().Truncate(24 * time.Hour)
+	records, err := r.records.GetWeatherHistory(ctx, city, start, start.Add(24*time.Hour-time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read records for %s on %s: %w", city, start.Format("2006-01-02"), err)
+	}
+
+	summary := SummarizeDay(city, start, records)
+	if summary == nil {
+		return nil, nil
+	}
+	if err := r.summaries.PutDailySummary(ctx, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// SummarizeDay computes a daily summary from one day's records, or nil when there are none.
+// Each record's precipitation is the amount over the preceding hour, so the day's total takes
+// the largest value observed in each clock hour; sampling more often than hourly doesn't inflate it.
+func SummarizeDay(city string, day time.Time, records []models.WeatherRecord) *models.DailySummary {
+	if len(records) == 0 {
+		return nil
+	}
+
+	summary := &models.DailySummary{
+		City:           city,
+		Date:           day.UTC().Format("2006-01-02"),
+		Readings:       len(records),
+		TemperatureMin: math.Inf(1),
+		TemperatureMax: math.Inf(-1),
+		CreatedAt:      time.Now().UTC(),
+	}
+	hourlyPrecipitation := make(map[int]float64)
+	var temperature, humidity, pressure, wind float64
+	for _, record := range records {
+		summary.TemperatureMin = math.Min(summary.TemperatureMin, record.Temperature)
+		summary.TemperatureMax = math.Max(summary.TemperatureMax, record.Temperature)
+		summary.WindSpeedMax = math.Max(summary.WindSpeedMax, record.WindSpeed)
+		temperature += record.Temperature
+		humidity += float64(record.Humidity)
+		pressure += float64(record.Pressure)
+		wind += record.WindSpeed
+
+		hour := record.ObservationTime().Hour()
+		hourlyPrecipitation[hour] = math.Max(hourlyPrecipitation[hour], record.Precipitation)
+	}
+
+	n := float64(len(records))
+	summary.TemperatureMean = round(temperature / n)
+	summary.HumidityMean = round(humidity / n)
+	summary.PressureMean = round(pressure / n)
+	summary.WindSpeedMean = round(wind / n)
+	for _, amount := range hourlyPrecipitation {
+		summary.Precipitation += amount
+	}
+	summary.Precipitation = round(summary.Precipitation)
+	return summary
+}
+
+// round keeps two decimals, enough for the provider's precision
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,71 @@
+// Package metrics publishes CloudWatch metrics using the Embedded Metric Format, which Lambda
+// extracts from log output without any PutMetricData calls.
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Namespace is the CloudWatch namespace metrics are published under
+const Namespace = "WeatherLambda"
+
+var (
+	mu     sync.Mutex
+	output io.Writer = os.Stdout
+)
+
+// SetOutput redirects metric lines, returning the previous writer
+func SetOutput(w io.Writer) io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	previous := output
+	output = w
+	return previous
+}
+
+// Count emits a count metric with the given dimensions
+func Count(name string, value int, dimensions map[string]string) {
+	emit(name, float64(value), "Count", dimensions)
+}
+
+// emit writes one metric as a single-line EMF document
+func emit(name string, value float64, unit string, dimensions map[string]string) {
+	keys := make([]string, 0, len(dimensions))
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	document := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": time.Now().UnixMilli(),
+			"CloudWatchMetrics": []map[string]interface{}{{
+				"Namespace":  Namespace,
+				"Dimensions": [][]string{keys},
+				"Metrics":    []map[string]string{{"Name": name, "Unit": unit}},
+			}},
+		},
+		name: value,
+	}
+	for key, dimension := range dimensions {
+		document[key] = dimension
+	}
+
+	line, err := json.Marshal(document)
+	if err != nil {
+		log.Printf("Failed to encode metric %s: %v", name, err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, err := output.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write metric %s: %v", name, err)
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Aggregate periods
+const (
+	AggregateHourly = "hourly"
+	AggregateDaily  = "daily"
+)
+
+// AggregateStats accumulates one measurement over an aggregate's readings
+type AggregateStats struct {
+	Min float64 `json:"min" dynamodbav:"min"`
+	Max float64 `json:"max" dynamodbav:"max"`
+	Sum float64 `json:"sum" dynamodbav:"sum"`
+}
+
+// AggregateValues are the measurements of one reading that aggregates accumulate
+type AggregateValues struct {
+	Temperature   float64
+	Humidity      float64
+	Pressure      float64
+	WindSpeed     float64
+	Precipitation float64
+}
+
+// Aggregate holds running statistics for a city over one UTC hour or day, maintained
+// from the records table's stream
+type Aggregate struct {
+	City   string `json:"city" dynamodbav:"city"`
+	Period string `json:"period" dynamodbav:"period"`
+	// Start is the UTC hour (2006-01-02T15) or day (2006-01-02) the aggregate covers
+	Start         string         `json:"start" dynamodbav:"timestamp"`
+	Count         int            `json:"count" dynamodbav:"count"`
+	Temperature   AggregateStats `json:"temperature" dynamodbav:"temperature"`
+	Humidity      AggregateStats `json:"humidity" dynamodbav:"humidity"`
+	Pressure      AggregateStats `json:"pressure" dynamodbav:"pressure"`
+	WindSpeed     AggregateStats `json:"windSpeed" dynamodbav:"windSpeed"`
+	Precipitation AggregateStats `json:"precipitation" dynamodbav:"precipitation"`
+	// Applied maps each recently counted record's key to a fingerprint of the values it
+	// contributed, so redelivered stream records are not counted twice
+	Applied   map[string]string `json:"-" dynamodbav:"applied"`
+	Version   int64             `json:"-" dynamodbav:"version"`
+	UpdatedAt time.Time         `json:"updatedAt" dynamodbav:"updatedAt"`
+}
+
+// Add accumulates a reading's values
+func (a *Aggregate) Add(values AggregateValues) {
+	first := a.Count == 0
+	a.Count++
+	a.Temperature.add(values.Temperature, first)
+	a.Humidity.add(values.Humidity, first)
+	a.Pressure.add(values.Pressure, first)
+	a.WindSpeed.add(values.WindSpeed, first)
+	a.Precipitation.add(values.Precipitation, first)
+}
+
+// Remove backs a reading's values out of the sums and count. Min and max can't be narrowed
+// incrementally, so they keep the removed values.
+func (a *Aggregate) Remove(values AggregateValues) {
+	a.Count--
+	a.Temperature.Sum -= values.Temperature
+	a.Humidity.Sum -= values.Humidity
+	a.Pressure.Sum -= values.Pressure
+	a.WindSpeed.Sum -= values.WindSpeed
+	a.Precipitation.Sum -= values.Precipitation
+}
+
+func (s *AggregateStats) add(value float64, first bool) {
+	if first || value < s.Min {
+		s.Min = value
+	}
+	if first || value > s.Max {
+		s.Max = value
+	}
+	s.Sum += value
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// CachedResponse represents the last weather API response seen for a location
+type CachedResponse struct {
+	Location     string          `json:"location"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	Response     WeatherResponse `json:"response"`
+	FetchedAt    time.Time       `json:"fetchedAt"`
+	// BoostedUntil raises the location's sampling rate until this time after a rapid change
+	BoostedUntil time.Time `json:"boostedUntil"`
+	BoostReason  string    `json:"boostReason,omitempty"`
+}
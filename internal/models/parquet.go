@@ -0,0 +1,25 @@
+package models
+
+// WeatherParquetRow is the schema of compacted daily Parquet files.
+// Timestamps are stored as UTC milliseconds since the epoch.
+type WeatherParquetRow struct {
+	ID            string  `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CityName      string  `parquet:"name=city_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Country       string  `parquet:"name=country, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ObservedAt    int64   `parquet:"name=observed_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	CollectedAt   int64   `parquet:"name=collected_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Latitude      float64 `parquet:"name=latitude, type=DOUBLE"`
+	Longitude     float64 `parquet:"name=longitude, type=DOUBLE"`
+	Temperature   float64 `parquet:"name=temperature, type=DOUBLE"`
+	FeelsLike     float64 `parquet:"name=feels_like, type=DOUBLE"`
+	TempMin       float64 `parquet:"name=temp_min, type=DOUBLE"`
+	TempMax       float64 `parquet:"name=temp_max, type=DOUBLE"`
+	Pressure      int32   `parquet:"name=pressure, type=INT32"`
+	Humidity      int32   `parquet:"name=humidity, type=INT32"`
+	WindSpeed     float64 `parquet:"name=wind_speed, type=DOUBLE"`
+	WindDeg       int32   `parquet:"name=wind_deg, type=INT32"`
+	Clouds        int32   `parquet:"name=clouds, type=INT32"`
+	Precipitation float64 `parquet:"name=precipitation, type=DOUBLE"`
+	Condition     string  `parquet:"name=condition, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description   string  `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
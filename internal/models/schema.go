@@ -0,0 +1,5 @@
+package models
+
+// CurrentSchemaVersion is the schema version written with new records and archived payloads.
+// Payloads without a schemaVersion attribute are version 0.
+const CurrentSchemaVersion = 1
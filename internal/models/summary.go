@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// DailySummary condenses one city's readings for a UTC day. Summaries outlive the raw
+// records, so history past the retention window stays queryable at daily resolution.
+type DailySummary struct {
+	City string `json:"city" dynamodbav:"city"`
+	// Date is the UTC day in YYYY-MM-DD form
+	Date            string  `json:"date" dynamodbav:"timestamp"`
+	Readings        int     `json:"readings" dynamodbav:"readings"`
+	TemperatureMin  float64 `json:"temperatureMin" dynamodbav:"temperatureMin"`
+	TemperatureMax  float64 `json:"temperatureMax" dynamodbav:"temperatureMax"`
+	TemperatureMean float64 `json:"temperatureMean" dynamodbav:"temperatureMean"`
+	HumidityMean    float64 `json:"humidityMean" dynamodbav:"humidityMean"`
+	PressureMean    float64 `json:"pressureMean" dynamodbav:"pressureMean"`
+	WindSpeedMean   float64 `json:"windSpeedMean" dynamodbav:"windSpeedMean"`
+	WindSpeedMax    float64 `json:"windSpeedMax" dynamodbav:"windSpeedMax"`
+	// Precipitation is the day's total rain and snow in mm
+	Precipitation float64   `json:"precipitation" dynamodbav:"precipitation"`
+	CreatedAt     time.Time `json:"createdAt" dynamodbav:"createdAt"`
+}
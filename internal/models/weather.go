@@ -4,14 +4,16 @@ import "time"
 
 // WeatherResponse represents the weather API response
 type WeatherResponse struct {
-	Name   string `json:"name"`
-	Coord  Coord  `json:"coord"`
-	Main   Main   `json:"main"`
-	Weather []Weather `json:"weather"`
-	Wind   Wind   `json:"wind"`
-	Clouds Clouds `json:"clouds"`
-	Sys    Sys    `json:"sys"`
-	Dt     int64  `json:"dt"`
+	Name    string         `json:"name"`
+	Coord   Coord          `json:"coord"`
+	Main    Main           `json:"main"`
+	Weather []Weather      `json:"weather"`
+	Wind    Wind           `json:"wind"`
+	Clouds  Clouds         `json:"clouds"`
+	Sys     Sys            `json:"sys"`
+	Dt      int64          `json:"dt"`
+	Rain    *Precipitation `json:"rain,omitempty"`
+	Snow    *Precipitation `json:"snow,omitempty"`
 }
 
 // Coord represents coordinates
@@ -49,6 +51,12 @@ type Clouds struct {
 	All int `json:"all"`
 }
 
+// Precipitation represents rain or snow volume in mm
+type Precipitation struct {
+	OneHour   float64 `json:"1h,omitempty"`
+	ThreeHour float64 `json:"3h,omitempty"`
+}
+
 // Sys represents system data
 type Sys struct {
 	Country string `json:"country"`
@@ -58,21 +66,36 @@ type Sys struct {
 
 // WeatherRecord represents data to be stored in DynamoDB
 type WeatherRecord struct {
-	ID          string    `json:"id" dynamodbav:"id"`
-	Timestamp   string    `json:"timestamp" dynamodbav:"timestamp"`
-	CityName    string    `json:"cityName" dynamodbav:"cityName"`
-	Temperature float64   `json:"temperature" dynamodbav:"temperature"`
-	Description string    `json:"description" dynamodbav:"description"`
-	Humidity    int       `json:"humidity" dynamodbav:"humidity"`
-	Pressure    int       `json:"pressure" dynamodbav:"pressure"`
-	WindSpeed   float64   `json:"windSpeed" dynamodbav:"windSpeed"`
-	Country     string    `json:"country" dynamodbav:"country"`
-	CreatedAt   time.Time `json:"createdAt" dynamodbav:"createdAt"`
-	TTL         int64     `json:"ttl" dynamodbav:"ttl"` // Time to live (30 days from creation)
+	ID            string    `json:"id" dynamodbav:"id"`
+	Timestamp     string    `json:"timestamp" dynamodbav:"timestamp"`
+	CityName      string    `json:"cityName" dynamodbav:"cityName"`
+	Temperature   float64   `json:"temperature" dynamodbav:"temperature"`
+	Description   string    `json:"description" dynamodbav:"description"`
+	Humidity      int       `json:"humidity" dynamodbav:"humidity"`
+	Pressure      int       `json:"pressure" dynamodbav:"pressure"`
+	WindSpeed     float64   `json:"windSpeed" dynamodbav:"windSpeed"`
+	Precipitation float64   `json:"precipitation" dynamodbav:"precipitation"` // Rain and snow over the last hour, in mm
+	Country       string    `json:"country" dynamodbav:"country"`
+	CreatedAt     time.Time `json:"createdAt" dynamodbav:"createdAt"`
+	ObservedAt    int64     `json:"observedAt,omitempty" dynamodbav:"observedAt,omitempty"` // Provider observation time (dt) in Unix seconds; 0 when unknown
+	TTL           int64     `json:"ttl" dynamodbav:"ttl"`                                   // Expiry in Unix seconds from the retention policy; 0 never expires
+	SchemaVersion int       `json:"schemaVersion" dynamodbav:"schemaVersion"`
+}
+
+// ObservationTime returns when the provider observed the reading, in UTC, falling back to the
+// collection time for records stored without one
+func (r *WeatherRecord) ObservationTime() time.Time {
+	if r.ObservedAt > 0 {
+		return time.Unix(r.ObservedAt, 0).UTC()
+	}
+	if ts, err := time.Parse(time.RFC3339, r.Timestamp); err == nil {
+		return ts.UTC()
+	}
+	return r.CreatedAt.UTC()
 }
 
 // S3WeatherData represents data to be stored in S3
 type S3WeatherData struct {
 	WeatherRecord
 	RawResponse WeatherResponse `json:"rawResponse"`
-}
\ No newline at end of file
+}
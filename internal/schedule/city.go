@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CitySchedule configures when one city is collected
+type CitySchedule struct {
+	City string `json:"city"`
+	// Schedule is a cron expression, descriptor, @every interval or rate()/cron() form
+	Schedule string `json:"schedule"`
+	// ActiveHours limits collection to a daily window such as "06:00-22:00"; empty means all day.
+	// A window ending before it starts wraps past midnight.
+	ActiveHours string `json:"activeHours,omitempty"`
+	// Timezone is the IANA zone for cron fields and active hours, defaulting to UTC
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// CityPlan decides which cities are due on each collection tick
+type CityPlan struct {
+	cities []plannedCity
+}
+
+type plannedCity struct {
+	name     string
+	schedule Schedule
+	loc      *time.Location
+	// active window in minutes of the day; start == end means all day
+	start, end int
+}
+
+// ParseCitySchedules parses a JSON array of city schedules
+func ParseCitySchedules(data []byte) (*CityPlan, error) {
+	var schedules []CitySchedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse city schedules: %w", err)
+	}
+	return NewCityPlan(schedules)
+}
+
+// NewCityPlan validates the schedules and builds a plan
+func NewCityPlan(schedules []CitySchedule) (*CityPlan, error) {
+	plan := &CityPlan{}
+	seen := make(map[string]bool)
+	for _, cs := range schedules {
+		if cs.City == "" {
+			return nil, fmt.Errorf("city schedule is missing a city")
+		}
+		if seen[cs.City] {
+			return nil, fmt.Errorf("city %s is scheduled more than once", cs.City)
+		}
+		seen[cs.City] = true
+
+		loc := time.UTC
+		if cs.Timezone != "" {
+			var err error
+			if loc, err = time.LoadLocation(cs.Timezone); err != nil {
+				return nil, fmt.Errorf("invalid timezone for %s: %w", cs.City, err)
+			}
+		}
+		sched, err := ParseIn(cs.Schedule, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule for %s: %w", cs.City, err)
+		}
+		start, end, err := parseActiveHours(cs.ActiveHours)
+		if err != nil {
+			return nil, fmt.Errorf("invalid active hours for %s: %w", cs.City, err)
+		}
+
+		plan.cities = append(plan.cities, plannedCity{name: cs.City, schedule: sched, loc: loc, start: start, end: end})
+	}
+	return plan, nil
+}
+
+// Cities returns every scheduled city in configuration order
+func (p *CityPlan) Cities() []string {
+	cities := make([]string, len(p.cities))
+	for i, city := range p.cities {
+		cities[i] = city.name
+	}
+	return cities
+}
+
+// Due returns the cities with a scheduled time in (at-tick, at] that falls within their
+// active hours. Evaluating each tick's window keeps the plan stateless between invocations.
+func (p *CityPlan) Due(at time.Time, tick time.Duration) []string {
+	var due []string
+	for _, city := range p.cities {
+		next := city.schedule.Next(at.Add(-tick))
+		if next.IsZero() || next.After(at) || !city.active(next) {
+			continue
+		}
+		due = append(due, city.name)
+	}
+	return due
+}
+
+// Active reports whether t falls within a scheduled city's active hours
+func (p *CityPlan) Active(city string, t time.Time) bool {
+	for _, c := range p.cities {
+		if c.name == city {
+			return c.active(t)
+		}
+	}
+	return false
+}
+
+// active reports whether t falls within the city's active hours
+func (c plannedCity) active(t time.Time) bool {
+	if c.start == c.end {
+		return true
+	}
+	local := t.In(c.loc)
+	minute := local.Hour()*60 + local.Minute()
+	if c.start < c.end {
+		return minute >= c.start && minute < c.end
+	}
+	return minute >= c.start || minute < c.end
+}
+
+// parseActiveHours parses "HH:MM-HH:MM" into minutes of the day
+func parseActiveHours(window string) (int, int, error) {
+	if window == "" {
+		return 0, 0, nil
+	}
+
+	var startHour, startMinute, endHour, endMinute int
+	if _, err := fmt.Sscanf(window, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute); err != nil {
+		return 0, 0, fmt.Errorf("expected HH:MM-HH:MM, got %q", window)
+	}
+	start, end := startHour*60+startMinute, endHour*60+endMinute
+	if startHour < 0 || endHour < 0 || startMinute < 0 || startMinute > 59 || endMinute < 0 || endMinute > 59 ||
+		start > 24*60 || end > 24*60 || start == end {
+		return 0, 0, fmt.Errorf("invalid window %q", window)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,300 @@
+// Package schedule parses collection schedules and runs jobs on them
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule reports when a job should next run
+type Schedule interface {
+	// Next returns the first run time strictly after t, or the zero time when there is none
+	Next(t time.Time) time.Time
+}
+
+// maxSearch bounds how far ahead Next looks for a matching cron time
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var dayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+// eventBridgeDayNames number days 1-7 from Sunday, as EventBridge cron expressions do
+var eventBridgeDayNames = map[string]int{
+	"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7,
+}
+
+// Parse accepts a five-field cron expression (minute hour day-of-month month day-of-week),
+// the descriptors @hourly, @daily, @weekly and @monthly, "@every <duration>", and the
+// EventBridge forms rate(<n> <unit>) and cron(<six fields>). Cron times are in UTC.
+func Parse(expr string) (Schedule, error) {
+	return ParseIn(expr, time.UTC)
+}
+
+// ParseIn is like Parse but evaluates cron fields in loc. Intervals are unaffected.
+func ParseIn(expr string, loc *time.Location) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case expr == "@hourly":
+		return parseCron(loc, strings.Fields("0 * * * *"), false)
+	case expr == "@daily", expr == "@midnight":
+		return parseCron(loc, strings.Fields("0 0 * * *"), false)
+	case expr == "@weekly":
+		return parseCron(loc, strings.Fields("0 0 * * 0"), false)
+	case expr == "@monthly":
+		return parseCron(loc, strings.Fields("0 0 1 * *"), false)
+	case strings.HasPrefix(expr, "@every "):
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil || every < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: @every needs a duration of at least 1m", expr)
+		}
+		return Every(every), nil
+	case strings.HasPrefix(expr, "rate(") && strings.HasSuffix(expr, ")"):
+		every, err := parseRate(expr[len("rate(") : len(expr)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		return Every(every), nil
+	case strings.HasPrefix(expr, "cron(") && strings.HasSuffix(expr, ")"):
+		fields := strings.Fields(expr[len("cron(") : len(expr)-1])
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("invalid schedule %q: expected 6 fields", expr)
+		}
+		if fields[5] != "*" {
+			return nil, fmt.Errorf("invalid schedule %q: the year field must be *", expr)
+		}
+		schedule, err := parseCron(loc, fields[:5], true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		return schedule, nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", expr)
+	}
+	schedule, err := parseCron(loc, fields, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+// Every runs at fixed intervals aligned to the Unix epoch, so rate(10 minutes) fires at :00, :10, ...
+type Every time.Duration
+
+// Next returns the next interval boundary after t
+func (e Every) Next(t time.Time) time.Time {
+	every := time.Duration(e)
+	return t.Truncate(every).Add(every)
+}
+
+// intervalWindow is how far ahead Interval checks a cron schedule's runs, long enough to
+// cover day-of-week fields
+const intervalWindow = 8 * 24 * time.Hour
+
+// Interval returns the fixed time between a schedule's runs after from. It fails for
+// schedules whose runs aren't evenly spaced, such as "0 9,17 * * *".
+func Interval(s Schedule, from time.Time) (time.Duration, error) {
+	if every, ok := s.(Every); ok {
+		return time.Duration(every), nil
+	}
+	var interval time.Duration
+	prev := s.Next(from)
+	for !prev.IsZero() && prev.Sub(from) < intervalWindow {
+		next := s.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); interval == 0 {
+			interval = gap
+		} else if gap != interval {
+			return 0, fmt.Errorf("schedule runs are not evenly spaced (%s, then %s)", interval, gap)
+		}
+		prev = next
+	}
+	if interval == 0 {
+		return 0, fmt.Errorf("schedule runs less than twice in %s", intervalWindow)
+	}
+	return interval, nil
+}
+
+// parseRate parses the body of an EventBridge rate expression
+func parseRate(rate string) (time.Duration, error) {
+	fields := strings.Fields(rate)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("expected rate(<value> <unit>)")
+	}
+	value, err := strconv.Atoi(fields[0])
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid rate value %q", fields[0])
+	}
+
+	switch strings.TrimSuffix(fields[1], "s") {
+	case "minute":
+		return time.Duration(value) * time.Minute, nil
+	case "hour":
+		return time.Duration(value) * time.Hour, nil
+	case "day":
+		return time.Duration(value) * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid rate unit %q", fields[1])
+}
+
+// cron is a parsed cron expression; each field is a bit set of allowed values
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// A restricted day of month and day of week match when either does, as in standard cron
+	domAny, dowAny bool
+	loc            *time.Location
+}
+
+// parseCron parses minute, hour, day-of-month, month and day-of-week fields.
+// eventBridge numbers days of the week 1-7 from Sunday instead of 0-6.
+func parseCron(loc *time.Location, fields []string, eventBridge bool) (*cron, error) {
+	minute, err := parseField(fields[0], 0, 59, nil)
+	if err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	hour, err := parseField(fields[1], 0, 23, nil)
+	if err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	dom, err := parseField(fields[2], 1, 31, nil)
+	if err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	month, err := parseField(fields[3], 1, 12, monthNames)
+	if err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+
+	var dow uint64
+	if eventBridge {
+		if dow, err = parseField(fields[4], 1, 7, eventBridgeDayNames); err == nil {
+			dow >>= 1
+		}
+	} else if dow, err = parseField(fields[4], 0, 7, dayNames); err == nil && dow&(1<<7) != 0 {
+		// 7 is an alias for Sunday
+		dow = dow&^(1<<7) | 1
+	}
+	if err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+
+	return &cron{
+		minute: minute,
+		hour:   hour,
+		dom:    dom,
+		month:  month,
+		dow:    dow,
+		domAny: isAny(fields[2]),
+		dowAny: isAny(fields[4]),
+		loc:    loc,
+	}, nil
+}
+
+// parseField parses a comma-separated list of values, ranges and steps into a bit set
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart = part[:i]
+		}
+
+		var low, high int
+		switch {
+		case isAny(rangePart):
+			low, high = min, max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(bounds[1], names); err != nil {
+				return 0, err
+			}
+		default:
+			value, err := parseValue(rangePart, names)
+			if err != nil {
+				return 0, err
+			}
+			low, high = value, value
+			if step > 1 {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a number or a month or day name
+func parseValue(value string, names map[string]int) (int, error) {
+	if named, ok := names[strings.ToUpper(value)]; ok {
+		return named, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return parsed, nil
+}
+
+// isAny reports whether a field matches every value; EventBridge uses ? for the unused day field
+func isAny(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// Next returns the first matching minute after t, in the schedule's location
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case c.month&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, c.loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, c.loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the cron day-of-month and day-of-week rules
+func (c *cron) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
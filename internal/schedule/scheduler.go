@@ -0,0 +1,70 @@
+package schedule
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a named task run on a schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context)
+}
+
+// Scheduler runs jobs on their schedules. A job never overlaps itself: runs missed while
+// it was busy are skipped rather than queued.
+type Scheduler struct {
+	jobs []Job
+	now  func() time.Time
+}
+
+// NewScheduler creates an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{now: time.Now}
+}
+
+// Add registers a job
+func (s *Scheduler) Add(name string, schedule Schedule, run func(ctx context.Context)) {
+	s.jobs = append(s.jobs, Job{Name: name, Schedule: schedule, Run: run})
+}
+
+// Run starts every job and blocks until ctx is cancelled and in-flight runs have returned.
+// In-flight runs are not cancelled with ctx so a collection isn't cut off halfway.
+func (s *Scheduler) Run(ctx context.Context) {
+	runCtx := context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.loop(ctx, runCtx, job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// loop waits for each of the job's run times until ctx is cancelled
+func (s *Scheduler) loop(ctx, runCtx context.Context, job Job) {
+	for {
+		next := job.Schedule.Next(s.now())
+		if next.IsZero() {
+			log.Printf("Scheduled job %s has no future run time, stopping", job.Name)
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		log.Printf("Running scheduled job %s", job.Name)
+		job.Run(runCtx)
+	}
+}
@@ -0,0 +1,155 @@
+// Package schema migrates stored weather payloads from older schema versions on read.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/weather-lambda/internal/models"
+)
+
+// VersionAttribute names the attribute holding a payload's schema version
+const VersionAttribute = "schemaVersion"
+
+// Document is a stored payload decoded into generic JSON values
+type Document map[string]interface{}
+
+// Upgrade migrates a document from one schema version to the next
+type Upgrade func(doc Document) error
+
+// MigrationError reports a payload that could not be upgraded or decoded
+type MigrationError struct {
+	Version int
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("failed to migrate schema version %d: %v", e.Version, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	mu       sync.RWMutex
+	upgrades = make(map[int]Upgrade)
+)
+
+// Register adds the upgrade from version to version+1, replacing any earlier registration
+func Register(version int, upgrade Upgrade) {
+	mu.Lock()
+	defer mu.Unlock()
+	upgrades[version] = upgrade
+}
+
+// Version returns the document's schema version, 0 when absent
+func Version(doc Document) int {
+	switch v := doc[VersionAttribute].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	default:
+		return 0
+	}
+}
+
+// Migrate upgrades doc in place to models.CurrentSchemaVersion
+func Migrate(doc Document) error {
+	version := Version(doc)
+	if version > models.CurrentSchemaVersion {
+		return &MigrationError{Version: version, Err: fmt.Errorf("newer than supported version %d", models.CurrentSchemaVersion)}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for ; version < models.CurrentSchemaVersion; version++ {
+		upgrade, ok := upgrades[version]
+		if !ok {
+			return &MigrationError{Version: version, Err: fmt.Errorf("no upgrade registered")}
+		}
+		if err := upgrade(doc); err != nil {
+			return &MigrationError{Version: version, Err: err}
+		}
+		doc[VersionAttribute] = version + 1
+	}
+	return nil
+}
+
+// DecodeJSON migrates a JSON payload and decodes it into out
+func DecodeJSON(data []byte, out interface{}) error {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return &MigrationError{Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+	if Version(doc) == models.CurrentSchemaVersion {
+		if err := json.Unmarshal(data, out); err != nil {
+			return &MigrationError{Version: models.CurrentSchemaVersion, Err: err}
+		}
+		return nil
+	}
+
+	if err := Migrate(doc); err != nil {
+		return err
+	}
+	migrated, err := json.Marshal(doc)
+	if err == nil {
+		err = json.Unmarshal(migrated, out)
+	}
+	if err != nil {
+		return &MigrationError{Version: models.CurrentSchemaVersion, Err: err}
+	}
+	return nil
+}
+
+// DecodeItem migrates a DynamoDB item and decodes it into out
+func DecodeItem(item map[string]*dynamodb.AttributeValue, out interface{}) error {
+	if av, ok := item[VersionAttribute]; ok && av.N != nil && *av.N == strconv.Itoa(models.CurrentSchemaVersion) {
+		if err := dynamodbattribute.UnmarshalMap(item, out); err != nil {
+			return &MigrationError{Version: models.CurrentSchemaVersion, Err: err}
+		}
+		return nil
+	}
+
+	var doc Document
+	if err := dynamodbattribute.UnmarshalMap(item, &doc); err != nil {
+		return &MigrationError{Err: err}
+	}
+	version := Version(doc)
+	if err := Migrate(doc); err != nil {
+		return err
+	}
+	migrated, err := dynamodbattribute.MarshalMap(doc)
+	if err == nil {
+		err = dynamodbattribute.UnmarshalMap(migrated, out)
+	}
+	if err != nil {
+		return &MigrationError{Version: version, Err: err}
+	}
+	return nil
+}
+
+// number reads a numeric document value, accepting numeric strings written by older payloads
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+)
+
+func init() {
+	Register(0, upgradeV0)
+}
+
+// upgradeV0 migrates payloads written before schema versioning. Hand-written and imported
+// payloads may carry numbers as strings or omit createdAt, which previously made them unreadable.
+func upgradeV0(doc Document) error {
+	for _, field := range []string{"temperature", "windSpeed"} {
+		if err := coerceNumber(doc, field, false); err != nil {
+			return err
+		}
+	}
+	for _, field := range []string{"humidity", "pressure", "ttl"} {
+		if err := coerceNumber(doc, field, true); err != nil {
+			return err
+		}
+	}
+
+	if created, _ := doc["createdAt"].(string); created == "" {
+		if timestamp, ok := doc["timestamp"].(string); ok && timestamp != "" {
+			doc["createdAt"] = timestamp
+		} else {
+			delete(doc, "createdAt")
+		}
+	}
+	return nil
+}
+
+// coerceNumber normalizes a numeric field, rounding it when the current schema stores an integer
+func coerceNumber(doc Document, field string, integer bool) error {
+	value, ok := doc[field]
+	if !ok || value == nil {
+		return nil
+	}
+	n, ok := number(value)
+	if !ok {
+		return fmt.Errorf("field %s is not numeric: %v", field, value)
+	}
+	if integer {
+		n = math.Round(n)
+	}
+	doc[field] = n
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package server adapts the Lambda handlers to net/http for the self-hosted server
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxBodyBytes caps request bodies, matching API Gateway's payload limit
+const maxBodyBytes = 10 << 20
+
+// ProxyHandler is the signature of an API Gateway proxy Lambda handler
+type ProxyHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// APIGatewayHandler serves a proxy handler over net/http, translating requests and
+// responses the way API Gateway does
+func APIGatewayHandler(handle ProxyHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, err := proxyRequest(r)
+		if err != nil {
+			writeMessage(w, http.StatusRequestEntityTooLarge, "Request too large")
+			return
+		}
+
+		response, err := handle(r.Context(), request)
+		if err != nil {
+			log.Printf("Error handling %s %s: %v", r.Method, r.URL.Path, err)
+			writeMessage(w, http.StatusBadGateway, "Internal server error")
+			return
+		}
+		writeProxyResponse(w, response)
+	})
+}
+
+// RequireAPIKey rejects requests without a matching X-Api-Key header, as an API Gateway
+// usage plan does. An empty key disables the check. CORS preflights are let through.
+func RequireAPIKey(key string, next http.Handler) http.Handler {
+	if key == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Api-Key")), []byte(key)) != 1 {
+			writeMessage(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// proxyRequest converts an HTTP request into an API Gateway proxy request
+func proxyRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	// Handlers look headers up by the client's spelling, so offer both the canonical and lower-case forms
+	headers := make(map[string]string, 2*len(r.Header))
+	for name, values := range r.Header {
+		headers[name] = values[0]
+		headers[strings.ToLower(name)] = values[0]
+	}
+	query := make(map[string]string, len(r.URL.Query()))
+	for name, values := range r.URL.Query() {
+		query[name] = values[0]
+	}
+
+	return events.APIGatewayProxyRequest{
+		Resource:                        r.URL.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               r.Header,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: r.URL.Query(),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			HTTPMethod: r.Method,
+			Path:       r.URL.Path,
+			Identity:   events.APIGatewayRequestIdentity{SourceIP: r.RemoteAddr},
+		},
+		Body: string(body),
+	}, nil
+}
+
+// writeProxyResponse writes an API Gateway proxy response
+func writeProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) {
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			log.Printf("Error decoding base64 response body: %v", err)
+			writeMessage(w, http.StatusBadGateway, "Internal server error")
+			return
+		}
+		body = decoded
+	}
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// writeMessage writes an API Gateway style {"message": ...} error
+func writeMessage(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = io.WriteString(w, `{"message": "`+message+`"}`)
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/models"
+)
+
+// precipitationWords mark descriptions of falling rain or snow
+var precipitationWords = []string{"rain", "drizzle", "snow", "sleet", "thunderstorm", "shower"}
+
+// RapidChanges compares a reading with the previous one for the same city and describes each
+// rate-of-change threshold it crosses. Rates use the provider's observation times, since unchanged
+// observations aren't stored and collection times can drift from them. Readings observed too close
+// together or too far apart are not compared.
+func RapidChanges(previous, current *models.WeatherRecord, cfg config.AdaptiveConfig) []string {
+	elapsed := current.ObservationTime().Sub(previous.ObservationTime())
+	if elapsed < time.Minute || (cfg.MaxGap > 0 && elapsed > cfg.MaxGap) {
+		return nil
+	}
+	hours := elapsed.Hours()
+
+	var changes []string
+	if drop := float64(previous.Pressure-current.Pressure) / hours; cfg.PressureDropPerHour > 0 && drop >= cfg.PressureDropPerHour {
+		changes = append(changes, fmt.Sprintf("pressure falling %.1f hPa/h", drop))
+	}
+	if change := (current.Temperature - previous.Temperature) / hours; cfg.TemperatureChangePerHour > 0 &&
+		(change >= cfg.TemperatureChangePerHour || -change >= cfg.TemperatureChangePerHour) {
+		changes = append(changes, fmt.Sprintf("temperature changing %+.1f°C/h", change))
+	}
+	if increase := (current.WindSpeed - previous.WindSpeed) / hours; cfg.WindIncreasePerHour > 0 && increase >= cfg.WindIncreasePerHour {
+		changes = append(changes, fmt.Sprintf("wind rising %.1f m/s per hour", increase))
+	}
+	if cfg.PrecipitationOnset && !precipitating(previous) && precipitating(current) {
+		changes = append(changes, "precipitation started")
+	}
+	return changes
+}
+
+// precipitating reports whether a reading shows rain or snow
+func precipitating(record *models.WeatherRecord) bool {
+	if record.Precipitation > 0 {
+		return true
+	}
+	description := strings.ToLower(record.Description)
+	for _, word := range precipitationWords {
+		if strings.Contains(description, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/weather-lambda/internal/models"
+)
+
+// ResponseCache stores the last weather API response per location
+type ResponseCache interface {
+	Get(location string) (*models.CachedResponse, error)
+	Put(entry *models.CachedResponse) error
+}
+
+// MemoryResponseCache keeps cached responses in memory so they survive warm invocations.
+// An optional backing cache is consulted on misses and written through on updates.
+type MemoryResponseCache struct {
+	mu      sync.RWMutex
+	entries map[string]*models.CachedResponse
+	backing ResponseCache
+}
+
+// NewMemoryResponseCache creates a new in-memory response cache; backing may be nil
+func NewMemoryResponseCache(backing ResponseCache) *MemoryResponseCache {
+	return &MemoryResponseCache{
+		entries: make(map[string]*models.CachedResponse),
+		backing: backing,
+	}
+}
+
+// Get returns the cached response for a location, or nil when nothing is cached
+func (c *MemoryResponseCache) Get(location string) (*models.CachedResponse, error) {
+	key := cacheKey(location)
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok || c.backing == nil {
+		return entry, nil
+	}
+
+	entry, err := c.backing.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+
+	return entry, nil
+}
+
+// Put stores a response in memory and writes it through to the backing cache
+func (c *MemoryResponseCache) Put(entry *models.CachedResponse) error {
+	c.mu.Lock()
+	c.entries[cacheKey(entry.Location)] = entry
+	c.mu.Unlock()
+
+	if c.backing != nil {
+		if err := c.backing.Put(entry); err != nil {
+			// The in-memory entry is still valid, so a backing failure only costs a full request later
+			log.Printf("Failed to persist cached response for %s: %v", entry.Location, err)
+		}
+	}
+
+	return nil
+}
+
+// cacheKey normalizes a location so "Tokyo" and "tokyo" share an entry
+func cacheKey(location string) string {
+	return strings.ToLower(strings.TrimSpace(location))
+}
@@ -4,18 +4,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"time"
 
 	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/errs"
 	"github.com/weather-lambda/internal/models"
 )
 
+// weatherAPIService names the provider in UpstreamErrors
+const weatherAPIService = "weather API"
+
 // WeatherService handles weather API interactions
 type WeatherService struct {
-	config *config.Config
-	client *http.Client
+	config    *config.Config
+	client    *http.Client
+	cache     ResponseCache
+	retention config.RetentionConfig
+}
+
+// FetchResult represents the outcome of a cache-aware weather API request
+type FetchResult struct {
+	Response *models.WeatherResponse
+	// Changed is false when the provider's observation time matches the cached response
+	Changed bool
+	// NotModified is true when the provider answered a conditional request with 304
+	NotModified bool
+
+	entry *models.CachedResponse
 }
 
 // NewWeatherService creates a new weather service
@@ -25,36 +43,155 @@ func NewWeatherService(cfg *config.Config) *WeatherService {
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		cache:     NewMemoryResponseCache(nil),
+		retention: config.LoadRetention(),
 	}
 }
 
+// SetResponseCache replaces the cache used by FetchWeatherData
+func (w *WeatherService) SetResponseCache(cache ResponseCache) {
+	w.cache = cache
+}
+
+// SetRetention replaces the policy that sets record TTLs
+func (w *WeatherService) SetRetention(retention config.RetentionConfig) {
+	w.retention = retention
+}
+
 // GetWeatherData fetches weather data from the API
 func (w *WeatherService) GetWeatherData() (*models.WeatherResponse, error) {
+	resp, err := w.doRequest(w.config.Weather.CityName, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeWeatherResponse(resp)
+}
+
+// FetchWeatherData fetches weather data for a city, using the cached response
+// for conditional requests and to detect observations that haven't changed
+func (w *WeatherService) FetchWeatherData(city string) (*FetchResult, error) {
+	cached, err := w.cache.Get(city)
+	if err != nil {
+		// A broken cache must not stop collection
+		log.Printf("Failed to read cached response for %s: %v", city, err)
+		cached = nil
+	}
+
+	resp, err := w.doRequest(city, cached)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		response := cached.Response
+		return &FetchResult{Response: &response, Changed: false, NotModified: true}, nil
+	}
+
+	weatherResponse, err := decodeWeatherResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &models.CachedResponse{
+		Location:     city,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Response:     *weatherResponse,
+		FetchedAt:    time.Now(),
+	}
+	if cached != nil {
+		// Keep an active boost until the collector extends or lets it lapse
+		entry.BoostedUntil, entry.BoostReason = cached.BoostedUntil, cached.BoostReason
+	}
+
+	return &FetchResult{
+		Response: weatherResponse,
+		Changed:  cached == nil || cached.Response.Dt != weatherResponse.Dt,
+		entry:    entry,
+	}, nil
+}
+
+// Boost raises the location's sampling rate until the given time. It is persisted by RememberResponse.
+func (r *FetchResult) Boost(until time.Time, reason string) {
+	if r.entry != nil {
+		r.entry.BoostedUntil, r.entry.BoostReason = until, reason
+	}
+}
+
+// BoostedUntil returns when a city's sampling boost ends, or the zero time when it has none
+func (w *WeatherService) BoostedUntil(city string) time.Time {
+	cached, err := w.cache.Get(city)
+	if err != nil {
+		log.Printf("Failed to read cached response for %s: %v", city, err)
+		return time.Time{}
+	}
+	if cached == nil {
+		return time.Time{}
+	}
+	return cached.BoostedUntil
+}
+
+// RememberResponse caches a fetched response. Call it once the observation has been
+// stored, so a failed run is retried in full rather than skipped as unchanged.
+func (w *WeatherService) RememberResponse(result *FetchResult) error {
+	if result.entry == nil {
+		return nil
+	}
+	return w.cache.Put(result.entry)
+}
+
+// doRequest sends the weather API request for a city, adding conditional headers from cached.
+// The caller must close the response body.
+func (w *WeatherService) doRequest(city string, cached *models.CachedResponse) (*http.Response, error) {
 	baseURL, err := url.Parse(w.config.Weather.APIURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid weather API URL: %w", err)
 	}
 
 	params := url.Values{}
-	params.Add("q", w.config.Weather.CityName)
+	params.Add("q", city)
 	params.Add("appid", w.config.Weather.APIKey)
 	params.Add("units", "metric") // Celsius temperature
 	baseURL.RawQuery = params.Encode()
 
-	resp, err := w.client.Get(baseURL.String())
+	req, err := http.NewRequest(http.MethodGet, baseURL.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make weather API request: %w", err)
+		return nil, fmt.Errorf("failed to create weather API request: %w", err)
 	}
-	defer resp.Body.Close()
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, &errs.UpstreamError{Service: weatherAPIService, Err: fmt.Errorf("failed to make weather API request: %w", err)}
+	}
+
+	return resp, nil
+}
 
+// decodeWeatherResponse checks the status code and parses the weather API response body
+func decodeWeatherResponse(resp *http.Response) (*models.WeatherResponse, error) {
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("weather API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &errs.UpstreamError{
+			Service:    weatherAPIService,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("weather API returned status %d: %s", resp.StatusCode, string(body)),
+		}
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read weather API response: %w", err)
+		return nil, &errs.UpstreamError{Service: weatherAPIService, Err: fmt.Errorf("failed to read weather API response: %w", err)}
 	}
 
 	var weatherResponse models.WeatherResponse
@@ -67,18 +204,44 @@ func (w *WeatherService) GetWeatherData() (*models.WeatherResponse, error) {
 
 // ConvertToWeatherRecord converts API response to DynamoDB record
 func (w *WeatherService) ConvertToWeatherRecord(response *models.WeatherResponse) *models.WeatherRecord {
-	now := time.Now()
-	ttl := now.Add(30 * 24 * time.Hour).Unix() // 30 days TTL
+	return w.convertAt(response, time.Now())
+}
+
+// RederiveWeatherRecord re-runs the current conversion on an archived raw response,
+// keeping the archived record's ID, timestamp and collection time
+func (w *WeatherService) RederiveWeatherRecord(data *models.S3WeatherData) *models.WeatherRecord {
+	collected := data.CreatedAt
+	if collected.IsZero() {
+		if ts, err := time.Parse(time.RFC3339, data.Timestamp); err == nil {
+			collected = ts
+		}
+	}
+
+	record := w.convertAt(&data.RawResponse, collected)
+	if data.ID != "" {
+		record.ID = data.ID
+	}
+	if data.Timestamp != "" {
+		record.Timestamp = data.Timestamp
+	}
+	return record
+}
+
+// convertAt converts an API response collected at now
+func (w *WeatherService) convertAt(response *models.WeatherResponse, now time.Time) *models.WeatherRecord {
+	ttl := w.retention.ExpiresAt(response.Name, now) // 0 keeps the record forever
 
 	record := &models.WeatherRecord{
-		ID:        fmt.Sprintf("%s-%d", response.Name, now.Unix()),
-		Timestamp: now.Format(time.RFC3339),
-		CityName:  response.Name,
-		Humidity:  response.Main.Humidity,
-		Pressure:  response.Main.Pressure,
-		Country:   response.Sys.Country,
-		CreatedAt: now,
-		TTL:       ttl,
+		ID:            fmt.Sprintf("%s-%d", response.Name, now.Unix()),
+		Timestamp:     now.Format(time.RFC3339),
+		CityName:      response.Name,
+		Humidity:      response.Main.Humidity,
+		Pressure:      response.Main.Pressure,
+		Country:       response.Sys.Country,
+		CreatedAt:     now,
+		ObservedAt:    response.Dt,
+		TTL:           ttl,
+		SchemaVersion: models.CurrentSchemaVersion,
 	}
 
 	// Set temperature
@@ -92,6 +255,14 @@ func (w *WeatherService) ConvertToWeatherRecord(response *models.WeatherResponse
 	// Set wind speed
 	record.WindSpeed = response.Wind.Speed
 
+	// Set precipitation over the last hour
+	if response.Rain != nil {
+		record.Precipitation += response.Rain.OneHour
+	}
+	if response.Snow != nil {
+		record.Precipitation += response.Snow.OneHour
+	}
+
 	return record
 }
 
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/weather-lambda/internal/models"
+)
+
+// recordLatestStore reads the latest reading from a record store's most recent record, for
+// backends where a city query is already cheap
+type recordLatestStore struct {
+	records RecordStore
+}
+
+// NewRecordLatestStore serves the latest readings from records
+func NewRecordLatestStore(records RecordStore) LatestStore {
+	return &recordLatestStore{records: records}
+}
+
+// GetLatestWeather returns the city's most recent unexpired record
+func (s *recordLatestStore) GetLatestWeather(ctx context.Context, city string) (*models.WeatherRecord, error) {
+	records, err := s.records.QueryWeatherRecordsByCity(city, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no readings for %s: %w", city, ErrNotFound)
+	}
+	return records[0], nil
+}
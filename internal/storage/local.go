@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/schema"
+)
+
+// tempPrefix marks partially written files, which listings skip
+const tempPrefix = ".tmp-"
+
+// LocalBlobStore keeps blobs as files under a root directory, one file per key
+type LocalBlobStore struct {
+	root string
+}
+
+// NewLocalBlobStore creates a blob store rooted at dir, creating it if needed
+func NewLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &LocalBlobStore{root: dir}, nil
+}
+
+// Put writes body to the key's file atomically. Content type, encoding and metadata are not kept;
+// archive reads detect compression from the object bytes.
+func (s *LocalBlobStore) Put(ctx context.Context, key string, body []byte, opts PutOptions) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, body)
+}
+
+// Get reads the key's file
+func (s *LocalBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("blob %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// List walks the directories under the prefix and returns matching keys as a single page
+func (s *LocalBlobStore) List(ctx context.Context, opts ListOptions, fn func(page ListPage) bool) error {
+	// Only walk the deepest directory the prefix pins down
+	dir := s.root
+	if i := strings.LastIndex(opts.Prefix, "/"); i >= 0 {
+		var err error
+		if dir, err = s.path(opts.Prefix[:i]); err != nil {
+			return err
+		}
+	}
+
+	var objects []Object
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return ctx.Err()
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.root, file)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: filepath.ToSlash(rel), Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	fn(listPage(objects, opts))
+	return nil
+}
+
+// Copy duplicates a blob under a new key
+func (s *LocalBlobStore) Copy(ctx context.Context, sourceKey, destinationKey string) error {
+	body, err := s.Get(ctx, sourceKey)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, destinationKey, body, PutOptions{})
+}
+
+// Delete removes the key's file; deleting a missing key is not an error, as in S3
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	return nil
+}
+
+// path maps a key to a file under the root, rejecting keys that would escape it
+func (s *LocalBlobStore) path(key string) (string, error) {
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid blob key: %s", key)
+		}
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// LocalRecordStore keeps weather records as JSON files under a root directory,
+// one directory per record ID. Queries scan every file, which suits laptop-sized data.
+type LocalRecordStore struct {
+	root string
+	now  func() time.Time
+}
+
+// NewLocalRecordStore creates a record store rooted at dir, creating it if needed
+func NewLocalRecordStore(dir string) (*LocalRecordStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create record directory: %w", err)
+	}
+	return &LocalRecordStore{root: dir, now: time.Now}, nil
+}
+
+// StoreWeatherRecord writes or replaces a record's file
+func (s *LocalRecordStore) StoreWeatherRecord(record *models.WeatherRecord) error {
+	body, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal weather record: %w", err)
+	}
+	return writeFileAtomic(s.path(record.ID, record.Timestamp), body)
+}
+
+// GetWeatherRecord reads a record by its key
+func (s *LocalRecordStore) GetWeatherRecord(id, timestamp string) (*models.WeatherRecord, error) {
+	body, err := os.ReadFile(s.path(id, timestamp))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("weather record not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weather record: %w", err)
+	}
+
+	var record models.WeatherRecord
+	if err := schema.DecodeJSON(body, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal weather record: %w", err)
+	}
+	if expired(&record, s.now()) {
+		return nil, fmt.Errorf("weather record not found: %w", ErrNotFound)
+	}
+	return &record, nil
+}
+
+// QueryWeatherRecordsByCity returns a city's records, most recent first
+func (s *LocalRecordStore) QueryWeatherRecordsByCity(cityName string, limit int64) ([]*models.WeatherRecord, error) {
+	records, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	return queryByCity(records, cityName, limit), nil
+}
+
+// QueryRecentWeatherRecords returns the records stored under id, most recent first
+func (s *LocalRecordStore) QueryRecentWeatherRecords(id string, limit int64) ([]*models.WeatherRecord, error) {
+	records, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	return queryByID(records, id, limit), nil
+}
+
+// GetWeatherHistory returns a city's records between startTime and endTime, oldest first
+func (s *LocalRecordStore) GetWeatherHistory(ctx context.Context, cityName string, startTime, endTime time.Time) ([]models.WeatherRecord, error) {
+	records, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	return history(records, cityName, startTime, endTime), nil
+}
+
+// all reads every unexpired record, skipping files that can't be migrated
+func (s *LocalRecordStore) all() ([]models.WeatherRecord, error) {
+	now := s.now()
+	var records []models.WeatherRecord
+	err := filepath.WalkDir(s.root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) || filepath.Ext(file) != ".json" {
+			return nil
+		}
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var record models.WeatherRecord
+		if err := schema.DecodeJSON(body, &record); err != nil {
+			log.Printf("Skipping unreadable local record %s: %v", file, err)
+			return nil
+		}
+		if !expired(&record, now) {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weather records: %w", err)
+	}
+	return records, nil
+}
+
+// path maps a record key to its file, escaping characters that aren't safe in file names
+func (s *LocalRecordStore) path(id, timestamp string) string {
+	return filepath.Join(s.root, fileName(id), fileName(timestamp)+".json")
+}
+
+// fileName escapes a key component into a single safe path segment
+func fileName(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), ".", "%2E")
+}
+
+// writeFileAtomic writes through a temporary file so readers never see partial content
+func writeFileAtomic(file string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", file, err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(file), tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(body); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if err := os.Rename(temp.Name(), file); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
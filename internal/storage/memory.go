@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/weather-lambda/internal/models"
+)
+
+// MemoryBlobStore keeps blobs in memory, for tests and single-process runs
+type MemoryBlobStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	body     []byte
+	modified time.Time
+}
+
+// NewMemoryBlobStore creates an empty in-memory blob store
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{objects: make(map[string]memoryBlob)}
+}
+
+// Put stores a copy of body under key
+func (s *MemoryBlobStore) Put(ctx context.Context, key string, body []byte, opts PutOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryBlob{body: append([]byte(nil), body...), modified: time.Now()}
+	return nil
+}
+
+// Get returns a copy of the blob stored under key
+func (s *MemoryBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	blob, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("blob %s: %w", key, ErrNotFound)
+	}
+	return append([]byte(nil), blob.body...), nil
+}
+
+// List returns all matching keys as a single page
+func (s *MemoryBlobStore) List(ctx context.Context, opts ListOptions, fn func(page ListPage) bool) error {
+	s.mu.RLock()
+	objects := make([]Object, 0, len(s.objects))
+	for key, blob := range s.objects {
+		objects = append(objects, Object{Key: key, Size: int64(len(blob.body)), LastModified: blob.modified})
+	}
+	s.mu.RUnlock()
+
+	fn(listPage(objects, opts))
+	return nil
+}
+
+// Copy duplicates a blob under a new key
+func (s *MemoryBlobStore) Copy(ctx context.Context, sourceKey, destinationKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blob, ok := s.objects[sourceKey]
+	if !ok {
+		return fmt.Errorf("blob %s: %w", sourceKey, ErrNotFound)
+	}
+	s.objects[destinationKey] = memoryBlob{body: blob.body, modified: time.Now()}
+	return nil
+}
+
+// Delete removes a blob; deleting a missing key is not an error, as in S3
+func (s *MemoryBlobStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+// MemoryRecordStore keeps weather records in memory, applying TTL expiry on read
+type MemoryRecordStore struct {
+	mu      sync.RWMutex
+	records map[recordKey]models.WeatherRecord
+	now     func() time.Time
+}
+
+type recordKey struct {
+	id        string
+	timestamp string
+}
+
+// NewMemoryRecordStore creates an empty in-memory record store
+func NewMemoryRecordStore() *MemoryRecordStore {
+	return &MemoryRecordStore{
+		records: make(map[recordKey]models.WeatherRecord),
+		now:     time.Now,
+	}
+}
+
+// StoreWeatherRecord stores or replaces a record
+func (s *MemoryRecordStore) StoreWeatherRecord(record *models.WeatherRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[recordKey{record.ID, record.Timestamp}] = *record
+	return nil
+}
+
+// GetWeatherRecord retrieves a record by its key
+func (s *MemoryRecordStore) GetWeatherRecord(id, timestamp string) (*models.WeatherRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[recordKey{id, timestamp}]
+	if !ok || expired(&record, s.now()) {
+		return nil, fmt.Errorf("weather record not found: %w", ErrNotFound)
+	}
+	return &record, nil
+}
+
+// QueryWeatherRecordsByCity returns a city's records, most recent first
+func (s *MemoryRecordStore) QueryWeatherRecordsByCity(cityName string, limit int64) ([]*models.WeatherRecord, error) {
+	return queryByCity(s.snapshot(), cityName, limit), nil
+}
+
+// QueryRecentWeatherRecords returns the records stored under id, most recent first
+func (s *MemoryRecordStore) QueryRecentWeatherRecords(id string, limit int64) ([]*models.WeatherRecord, error) {
+	return queryByID(s.snapshot(), id, limit), nil
+}
+
+// GetWeatherHistory returns a city's records between startTime and endTime, oldest first
+func (s *MemoryRecordStore) GetWeatherHistory(ctx context.Context, cityName string, startTime, endTime time.Time) ([]models.WeatherRecord, error) {
+	return history(s.snapshot(), cityName, startTime, endTime), nil
+}
+
+// snapshot copies the unexpired records
+func (s *MemoryRecordStore) snapshot() []models.WeatherRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	records := make([]models.WeatherRecord, 0, len(s.records))
+	for _, record := range s.records {
+		if !expired(&record, now) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// queryByCity selects a city's records, most recent first
+func queryByCity(records []models.WeatherRecord, cityName string, limit int64) []*models.WeatherRecord {
+	return newestFirst(records, limit, func(record *models.WeatherRecord) bool {
+		return record.CityName == cityName
+	})
+}
+
+// queryByID selects the records stored under id, most recent first
+func queryByID(records []models.WeatherRecord, id string, limit int64) []*models.WeatherRecord {
+	return newestFirst(records, limit, func(record *models.WeatherRecord) bool {
+		return record.ID == id
+	})
+}
+
+func newestFirst(records []models.WeatherRecord, limit int64, match func(*models.WeatherRecord) bool) []*models.WeatherRecord {
+	var matched []*models.WeatherRecord
+	for i := range records {
+		if match(&records[i]) {
+			matched = append(matched, &records[i])
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ObservationTime().After(matched[j].ObservationTime())
+	})
+	if limit > 0 && int64(len(matched)) > limit {
+		matched = matched[:limit]
+	}
+	return matched
+}
+
+// history selects a city's records observed in [startTime, endTime], oldest first, as the
+// DynamoDB city index does. Times are compared parsed, since RFC 3339 strings with different
+// offsets don't sort chronologically.
+func history(records []models.WeatherRecord, cityName string, startTime, endTime time.Time) []models.WeatherRecord {
+	var matched []models.WeatherRecord
+	for i := range records {
+		ts := records[i].ObservationTime()
+		if records[i].CityName == cityName && !ts.Before(startTime) && !ts.After(endTime) {
+			matched = append(matched, records[i])
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ObservationTime().Before(matched[j].ObservationTime())
+	})
+	return matched
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/schema"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+)
+
+// sqliteSchema creates the records table. observed_at holds the provider's observation time
+// (or the collection time for records without one) as Unix seconds so range queries use the
+// (city_name, observed_at) index.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS weather_records (
+	id          TEXT    NOT NULL,
+	timestamp   TEXT    NOT NULL,
+	city_name   TEXT    NOT NULL,
+	observed_at INTEGER NOT NULL,
+	ttl         INTEGER NOT NULL DEFAULT 0,
+	payload     TEXT    NOT NULL,
+	PRIMARY KEY (id, timestamp)
+);
+CREATE INDEX IF NOT EXISTS weather_records_city_observed ON weather_records (city_name, observed_at);
+CREATE INDEX IF NOT EXISTS weather_records_ttl ON weather_records (ttl) WHERE ttl > 0;
+`
+
+// SQLiteRecordStore keeps weather records in an embedded SQLite database. TTL expiry is
+// applied on read and expired rows are deleted by Purge, as DynamoDB's TTL sweeper does.
+type SQLiteRecordStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewSQLiteRecordStore opens or creates the database at path and applies the schema
+func NewSQLiteRecordStore(path string) (*SQLiteRecordStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	dsn := (&url.URL{
+		Scheme:   "file",
+		Opaque:   path,
+		RawQuery: "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+	}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %w", err)
+	}
+
+	return &SQLiteRecordStore{db: db, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *SQLiteRecordStore) Close() error {
+	return s.db.Close()
+}
+
+// StoreWeatherRecord inserts or replaces a record
+func (s *SQLiteRecordStore) StoreWeatherRecord(record *models.WeatherRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal weather record: %w", err)
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO weather_records (id, timestamp, city_name, observed_at, ttl, payload)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		record.ID, record.Timestamp, record.CityName, record.ObservationTime().Unix(), record.TTL, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to insert weather record: %w", err)
+	}
+
+	return nil
+}
+
+// GetWeatherRecord retrieves a record by its key
+func (s *SQLiteRecordStore) GetWeatherRecord(id, timestamp string) (*models.WeatherRecord, error) {
+	var payload string
+	err := s.db.QueryRow(`SELECT payload FROM weather_records
+		WHERE id = ? AND timestamp = ? AND (ttl = 0 OR ttl >= ?)`,
+		id, timestamp, s.now().Unix()).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("weather record not found: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather record: %w", err)
+	}
+
+	var record models.WeatherRecord
+	if err := schema.DecodeJSON([]byte(payload), &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal weather record: %w", err)
+	}
+	return &record, nil
+}
+
+// QueryWeatherRecordsByCity returns a city's records, most recent first
+func (s *SQLiteRecordStore) QueryWeatherRecordsByCity(cityName string, limit int64) ([]*models.WeatherRecord, error) {
+	records, err := s.query(context.Background(), `SELECT payload FROM weather_records
+		WHERE city_name = ? AND (ttl = 0 OR ttl >= ?)
+		ORDER BY observed_at DESC LIMIT ?`,
+		cityName, s.now().Unix(), sqlLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	return pointers(records), nil
+}
+
+// QueryRecentWeatherRecords returns the records stored under id, most recent first
+func (s *SQLiteRecordStore) QueryRecentWeatherRecords(id string, limit int64) ([]*models.WeatherRecord, error) {
+	records, err := s.query(context.Background(), `SELECT payload FROM weather_records
+		WHERE id = ? AND (ttl = 0 OR ttl >= ?)
+		ORDER BY timestamp DESC LIMIT ?`,
+		id, s.now().Unix(), sqlLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	return pointers(records), nil
+}
+
+// GetWeatherHistory returns a city's records between startTime and endTime, oldest first
+func (s *SQLiteRecordStore) GetWeatherHistory(ctx context.Context, cityName string, startTime, endTime time.Time) ([]models.WeatherRecord, error) {
+	return s.query(ctx, `SELECT payload FROM weather_records
+		WHERE city_name = ? AND observed_at BETWEEN ? AND ? AND (ttl = 0 OR ttl >= ?)
+		ORDER BY observed_at ASC`,
+		cityName, startTime.Unix(), endTime.Unix(), s.now().Unix())
+}
+
+// Purge deletes expired records, returning how many were removed
+func (s *SQLiteRecordStore) Purge(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM weather_records WHERE ttl > 0 AND ttl < ?`, s.now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired weather records: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// StartPurge runs Purge every interval until ctx is cancelled
+func (s *SQLiteRecordStore) StartPurge(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				purged, err := s.Purge(ctx)
+				if err != nil {
+					log.Printf("Error purging expired records: %v", err)
+				} else if purged > 0 {
+					log.Printf("Purged %d expired weather records", purged)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// query decodes the payload column of every returned row, skipping rows that can't be migrated
+func (s *SQLiteRecordStore) query(ctx context.Context, query string, args ...interface{}) ([]models.WeatherRecord, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query weather records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []models.WeatherRecord
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("failed to read weather record: %w", err)
+		}
+		var record models.WeatherRecord
+		if err := schema.DecodeJSON([]byte(payload), &record); err != nil {
+			log.Printf("Skipping unreadable SQLite record: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query weather records: %w", err)
+	}
+
+	return records, nil
+}
+
+// sqlLimit maps a non-positive limit to SQLite's "no limit"
+func sqlLimit(limit int64) int64 {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+func pointers(records []models.WeatherRecord) []*models.WeatherRecord {
+	result := make([]*models.WeatherRecord, len(records))
+	for i := range records {
+		result[i] = &records[i]
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+// Package storage defines the record and blob stores the collector and history API run on,
+// with in-memory and local-directory backends for running without AWS.
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/models"
+)
+
+// ErrNotFound is returned, possibly wrapped, when a blob or record does not exist. It is
+// errs.ErrNotFound, so store and service errors share one class.
+var ErrNotFound = errs.ErrNotFound
+
+// RecordStore stores and queries weather records. DynamoDBHandler is the AWS implementation.
+type RecordStore interface {
+	StoreWeatherRecord(record *models.WeatherRecord) error
+	GetWeatherRecord(id, timestamp string) (*models.WeatherRecord, error)
+	QueryWeatherRecordsByCity(cityName string, limit int64) ([]*models.WeatherRecord, error)
+	QueryRecentWeatherRecords(id string, limit int64) ([]*models.WeatherRecord, error)
+	GetWeatherHistory(ctx context.Context, cityName string, startTime, endTime time.Time) ([]models.WeatherRecord, error)
+}
+
+// SummaryStore stores daily summaries that outlive the raw records. DynamoDBHandler is the AWS implementation.
+type SummaryStore interface {
+	PutDailySummary(ctx context.Context, summary *models.DailySummary) error
+	// GetDailySummaries returns a city's summaries for the UTC days from start to end, oldest first
+	GetDailySummaries(ctx context.Context, city string, start, end time.Time) ([]models.DailySummary, error)
+}
+
+// LatestStore serves each city's most recent reading without a range query. DynamoDBHandler
+// is the AWS implementation.
+type LatestStore interface {
+	// GetLatestWeather returns an error wrapping ErrNotFound when the city has no readings
+	GetLatestWeather(ctx context.Context, city string) (*models.WeatherRecord, error)
+}
+
+// BlobStore stores opaque objects by key. S3 is the AWS implementation.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body []byte, opts PutOptions) error
+	// Get returns an error wrapping ErrNotFound when the key does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List calls fn for each page of keys in ascending order; returning false stops listing
+	List(ctx context.Context, opts ListOptions, fn func(page ListPage) bool) error
+	Copy(ctx context.Context, sourceKey, destinationKey string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// PutOptions describes an object being stored
+type PutOptions struct {
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+}
+
+// ListOptions narrows a listing
+type ListOptions struct {
+	Prefix string
+	// StartAfter skips keys up to and including this one
+	StartAfter string
+	// Delimiter groups keys sharing a prefix up to the delimiter into CommonPrefixes
+	Delimiter string
+}
+
+// ListPage is one page of listed objects
+type ListPage struct {
+	Objects        []Object
+	CommonPrefixes []string
+}
+
+// Object describes a stored blob
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// listPage applies ListOptions to a full set of objects, as S3 does server-side
+func listPage(objects []Object, opts ListOptions) ListPage {
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	var page ListPage
+	seen := make(map[string]bool)
+	for _, object := range objects {
+		if !strings.HasPrefix(object.Key, opts.Prefix) || object.Key <= opts.StartAfter {
+			continue
+		}
+		if opts.Delimiter != "" {
+			rest := object.Key[len(opts.Prefix):]
+			if i := strings.Index(rest, opts.Delimiter); i >= 0 {
+				common := opts.Prefix + rest[:i+len(opts.Delimiter)]
+				if !seen[common] {
+					seen[common] = true
+					page.CommonPrefixes = append(page.CommonPrefixes, common)
+				}
+				continue
+			}
+		}
+		page.Objects = append(page.Objects, object)
+	}
+	return page
+}
+
+// expired reports whether DynamoDB's TTL would have removed the record
+func expired(record *models.WeatherRecord, now time.Time) bool {
+	return record.TTL > 0 && record.TTL < now.Unix()
+}
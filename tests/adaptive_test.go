@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/services"
+)
+
+func TestRapidChanges(t *testing.T) {
+	cfg := config.AdaptiveConfig{
+		MaxGap:                   6 * time.Hour,
+		PressureDropPerHour:      2,
+		TemperatureChangePerHour: 3,
+		WindIncreasePerHour:      5,
+		PrecipitationOnset:       true,
+	}
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	reading := func(offset time.Duration, pressure int, temperature, wind float64, description string) *models.WeatherRecord {
+		return &models.WeatherRecord{
+			CityName:    "Tokyo",
+			CreatedAt:   start.Add(offset),
+			Pressure:    pressure,
+			Temperature: temperature,
+			WindSpeed:   wind,
+			Description: description,
+		}
+	}
+	previous := reading(0, 1012, 18, 3, "clear sky")
+
+	tests := []struct {
+		name    string
+		current *models.WeatherRecord
+		want    int
+	}{
+		{"steady", reading(time.Hour, 1011, 19, 4, "clear sky"), 0},
+		{"pressure drop", reading(30*time.Minute, 1010, 18, 3, "clear sky"), 1},
+		{"temperature fall", reading(time.Hour, 1012, 14, 3, "clear sky"), 1},
+		{"storm front", reading(time.Hour, 1008, 13, 10, "thunderstorm with rain"), 4},
+		{"slow drift over a long gap", reading(5*time.Hour, 1004, 18, 3, "clear sky"), 0},
+		{"previous too old", reading(8*time.Hour, 990, 5, 20, "light rain"), 0},
+	}
+	for _, tt := range tests {
+		if got := services.RapidChanges(previous, tt.current, cfg); len(got) != tt.want {
+			t.Errorf("%s: expected %d changes, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	raining := reading(0, 1012, 18, 3, "scattered clouds")
+	raining.Precipitation = 0.4
+	if got := services.RapidChanges(raining, reading(time.Hour, 1012, 18, 3, "light rain"), cfg); len(got) != 0 {
+		t.Errorf("Expected no onset while precipitation continues, got %v", got)
+	}
+
+	// The rate follows observation times, not when the readings happened to be collected
+	observed := reading(0, 1012, 18, 3, "clear sky")
+	observed.ObservedAt = start.Add(-15 * time.Minute).Unix()
+	late := reading(time.Hour, 1012, 19, 3, "clear sky")
+	late.ObservedAt = start.Add(-5 * time.Minute).Unix()
+	if got := services.RapidChanges(observed, late, cfg); len(got) != 1 {
+		t.Errorf("Expected 1°C over 10 observed minutes to cross the threshold, got %v", got)
+	}
+
+	cfg.PressureDropPerHour = 0
+	if got := services.RapidChanges(previous, reading(30*time.Minute, 1000, 18, 3, "clear sky"), cfg); len(got) != 0 {
+		t.Errorf("Expected a zero threshold to disable the pressure check, got %v", got)
+	}
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/weather-lambda/internal/aggregates"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/models"
+)
+
+// recordingAggregateStore keeps the changes applied per aggregate and fails the listed ones
+type recordingAggregateStore struct {
+	applied map[string][]handlers.AggregateChange
+	failing map[string]bool
+}
+
+func (s *recordingAggregateStore) ApplyAggregateChanges(ctx context.Context, city, period, start string, changes []handlers.AggregateChange) error {
+	key := city + "/" + period + "/" + start
+	if s.failing[key] {
+		return errors.New("unavailable")
+	}
+	s.applied[key] = append(s.applied[key], changes...)
+	return nil
+}
+
+func TestAggregateAddRemove(t *testing.T) {
+	var aggregate models.Aggregate
+	aggregate.Add(models.AggregateValues{Temperature: 10, Humidity: 50})
+	aggregate.Add(models.AggregateValues{Temperature: 4, Humidity: 70})
+	if aggregate.Count != 2 || aggregate.Temperature.Min != 4 || aggregate.Temperature.Max != 10 || aggregate.Temperature.Sum != 14 {
+		t.Errorf("Unexpected aggregate after adding: %+v", aggregate)
+	}
+
+	aggregate.Remove(models.AggregateValues{Temperature: 4, Humidity: 70})
+	aggregate.Add(models.AggregateValues{Temperature: 6, Humidity: 60})
+	if aggregate.Count != 2 || aggregate.Temperature.Sum != 16 || aggregate.Humidity.Sum != 110 {
+		t.Errorf("Unexpected aggregate after replacing: %+v", aggregate)
+	}
+}
+
+func TestAggregateStreamHandler(t *testing.T) {
+	store := &recordingAggregateStore{
+		applied: make(map[string][]handlers.AggregateChange),
+		failing: map[string]bool{"London/hourly/2024-03-15T09": true},
+	}
+	handler := aggregates.NewHandler(store)
+
+	image := func(id, city, timestamp, temperature string) map[string]events.DynamoDBAttributeValue {
+		return map[string]events.DynamoDBAttributeValue{
+			"id":          events.NewStringAttribute(id),
+			"timestamp":   events.NewStringAttribute(timestamp),
+			"cityName":    events.NewStringAttribute(city),
+			"temperature": events.NewNumberAttribute(temperature),
+		}
+	}
+	observed := func(image map[string]events.DynamoDBAttributeValue, at string) map[string]events.DynamoDBAttributeValue {
+		image["observedAt"] = events.NewNumberAttribute(at)
+		return image
+	}
+	record := func(name, sequence string, newImage, oldImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+		return events.DynamoDBEventRecord{
+			EventName: name,
+			Change:    events.DynamoDBStreamRecord{SequenceNumber: sequence, NewImage: newImage, OldImage: oldImage},
+		}
+	}
+
+	response, err := handler.HandleRequest(context.Background(), events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("INSERT", "1", image("Tokyo-1", "Tokyo", "2024-03-15T10:05:00Z", "12"), nil),
+		record("MODIFY", "2", image("Tokyo-1", "Tokyo", "2024-03-15T10:05:00Z", "13"), image("Tokyo-1", "Tokyo", "2024-03-15T10:05:00Z", "12")),
+		record("INSERT", "3", image("London-1", "London", "2024-03-15T09:55:00Z", "8"), nil),
+		record("REMOVE", "4", nil, image("Tokyo-0", "Tokyo", "2024-02-14T10:00:00Z", "5")),
+		record("INSERT", "5", map[string]events.DynamoDBAttributeValue{"id": events.NewStringAttribute("aggregate#daily#tokyo")}, nil),
+		record("MODIFY", "6", observed(image("Tokyo-2", "Tokyo", "2024-03-15T08:05:00Z", "9"), "1710490200"), observed(image("Tokyo-2", "Tokyo", "2024-03-15T08:05:00Z", "9"), "1710486600")),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to handle stream batch: %v", err)
+	}
+
+	hourly := store.applied["Tokyo/hourly/2024-03-15T10"]
+	if len(hourly) != 2 || hourly[1].Previous == nil || hourly[1].Previous.Temperature != 12 || hourly[1].Values.Temperature != 13 {
+		t.Errorf("Expected the insert and its modification, got %+v", hourly)
+	}
+	if daily := store.applied["Tokyo/daily/2024-03-15"]; len(daily) != 3 {
+		t.Errorf("Expected the Tokyo records in the daily aggregate, got %+v", daily)
+	}
+	// A modification that moves the reading to another hour backs it out of the old one
+	if moved := store.applied["Tokyo/hourly/2024-03-15T07"]; len(moved) != 1 || !moved[0].Removed || moved[0].Previous == nil || moved[0].Previous.Temperature != 9 {
+		t.Errorf("Expected the moved reading to be backed out of its old hour, got %+v", moved)
+	}
+	if moved := store.applied["Tokyo/hourly/2024-03-15T08"]; len(moved) != 1 || moved[0].Removed || moved[0].Previous != nil {
+		t.Errorf("Expected the moved reading to be added to its new hour, got %+v", moved)
+	}
+	if daily := store.applied["Tokyo/daily/2024-03-15"]; len(daily) != 3 || daily[2].Removed || daily[2].Previous == nil {
+		t.Errorf("Expected the moved reading to be replaced within its day, got %+v", daily)
+	}
+	if _, ok := store.applied["Tokyo/daily/2024-02-14"]; ok {
+		t.Error("Expected removals to be ignored")
+	}
+
+	// Only the record behind the failed aggregate is retried
+	if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "3" {
+		t.Errorf("Expected record 3 to be reported as failed, got %+v", response.BatchItemFailures)
+	}
+}
+
+func TestApplyAggregateChanges(t *testing.T) {
+	client := newFakeDynamoDB()
+	handler := handlers.NewDynamoDBHandlerFromClient(client, "weather")
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	recent := "Tokyo-2@" + now.Add(-time.Hour).Format(time.RFC3339)
+	old := "Tokyo-1@" + now.Add(-72*time.Hour).Format(time.RFC3339)
+	apply := func(changes ...handlers.AggregateChange) models.Aggregate {
+		t.Helper()
+		if err := handler.ApplyAggregateChanges(ctx, "Tokyo", models.AggregateDaily, "2024-03-15", changes); err != nil {
+			t.Fatalf("Failed to apply aggregate changes: %v", err)
+		}
+		aggregates, err := handler.GetAggregates(ctx, "Tokyo", models.AggregateDaily, "2024-03-15", "2024-03-15")
+		if err != nil || len(aggregates) != 1 {
+			t.Fatalf("Expected the aggregate, got %+v, %v", aggregates, err)
+		}
+		return aggregates[0]
+	}
+
+	apply(
+		handlers.AggregateChange{Key: old, Values: models.AggregateValues{Temperature: 10}},
+		handlers.AggregateChange{Key: recent, Values: models.AggregateValues{Temperature: 20}},
+	)
+	if aggregate := apply(handlers.AggregateChange{Key: recent, Values: models.AggregateValues{Temperature: 20}}); aggregate.Count != 2 || aggregate.Temperature.Sum != 30 {
+		t.Errorf("Expected the redelivered record to be skipped, got %+v", aggregate)
+	}
+
+	// Only records still within the replay window are remembered
+	for _, item := range client.Items("weather") {
+		if applied := item["applied"]; applied == nil || len(applied.M) != 1 || applied.M[recent] == nil {
+			t.Errorf("Expected only the recent record to be remembered, got %v", applied)
+		}
+	}
+
+	// A record moved to another aggregate is backed out once, and can move back
+	moved := models.AggregateValues{Temperature: 20}
+	removal := handlers.AggregateChange{Key: recent, Previous: &moved, Removed: true}
+	if aggregate := apply(removal, removal); aggregate.Count != 1 || aggregate.Temperature.Sum != 10 {
+		t.Errorf("Expected the moved record to be backed out once, got %+v", aggregate)
+	}
+	if aggregate := apply(handlers.AggregateChange{Key: recent, Values: models.AggregateValues{Temperature: 21}}); aggregate.Count != 2 || aggregate.Temperature.Sum != 31 {
+		t.Errorf("Expected the record to move back, got %+v", aggregate)
+	}
+
+	// A modification of a forgotten record replaces the values counted before it was pruned
+	previous := models.AggregateValues{Temperature: 10}
+	if aggregate := apply(handlers.AggregateChange{Key: old, Values: models.AggregateValues{Temperature: 12}, Previous: &previous}); aggregate.Count != 2 || aggregate.Temperature.Sum != 33 {
+		t.Errorf("Expected the old record's values to be replaced, got %+v", aggregate)
+	}
+}
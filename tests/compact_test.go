@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/jobs"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestCompactDeleteOriginalsGate(t *testing.T) {
+	s3Handler := handlers.NewS3HandlerFromStore(storage.NewMemoryBlobStore(), handlers.WithKeyLayout(handlers.KeyLayoutHive))
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		reading := newStoredReading("Tokyo", day.Add(time.Duration(i+1)*time.Hour), float64(20+i))
+		reading.Precipitation = float64(i) / 2
+		if err := s3Handler.StoreWeatherData(reading); err != nil {
+			t.Fatalf("Failed to store weather data: %v", err)
+		}
+	}
+	countOriginals := func() int {
+		objects, err := s3Handler.ListWeatherData(handlers.DefaultKeyPrefix + "/")
+		if err != nil {
+			t.Fatalf("Failed to list weather data: %v", err)
+		}
+		return len(objects)
+	}
+
+	compactor := jobs.NewCompactor(s3Handler, "curated/weather-daily", false)
+	if _, err := compactor.CompactDay(context.Background(), "Tokyo", day, true); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("Expected deleting originals to be refused, got %v", err)
+	}
+	result, err := compactor.CompactDay(context.Background(), "Tokyo", day, false)
+	if err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if result.Rows != 3 || result.Deleted != 0 || countOriginals() != 3 {
+		t.Errorf("Expected 3 rows compacted and the originals kept, got %+v", result)
+	}
+
+	// The Parquet rows keep everything the originals served, including precipitation
+	written, err := s3Handler.GetObject(result.Key)
+	if err != nil {
+		t.Fatalf("Failed to read the compacted file: %v", err)
+	}
+	file, err := buffer.NewBufferFile(written)
+	if err != nil {
+		t.Fatalf("Failed to open the compacted file: %v", err)
+	}
+	pr, err := reader.NewParquetReader(file, new(models.WeatherParquetRow), 1)
+	if err != nil {
+		t.Fatalf("Failed to read the compacted file: %v", err)
+	}
+	rows := make([]models.WeatherParquetRow, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		t.Fatalf("Failed to read compacted rows: %v", err)
+	}
+	pr.ReadStop()
+	if len(rows) != 3 || rows[2].Precipitation != 1 || rows[2].Temperature != 22 {
+		t.Errorf("Expected the readings' precipitation in the compacted rows, got %+v", rows)
+	}
+
+	compactor = jobs.NewCompactor(s3Handler, "curated/weather-daily", true)
+	if result, err = compactor.CompactDay(context.Background(), "Tokyo", day, true); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if result.Deleted != 3 || countOriginals() != 0 {
+		t.Errorf("Expected the originals deleted once allowed, got %+v", result)
+	}
+}
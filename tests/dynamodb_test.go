@@ -0,0 +1,483 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/weather-lambda/internal/awsfake"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/history"
+	"github.com/weather-lambda/internal/models"
+)
+
+var (
+	_ handlers.DynamoDBClient = (*awsfake.DynamoDB)(nil)
+	_ handlers.S3Client       = (*awsfake.S3)(nil)
+)
+
+// newFakeDynamoDB returns a fake with the records table's key schema and city index
+func newFakeDynamoDB() *awsfake.DynamoDB {
+	return awsfake.NewDynamoDB(
+		awsfake.KeySchema{Hash: "id", Range: "timestamp"},
+		map[string]awsfake.KeySchema{handlers.CityTimeIndex: {Hash: "cityName", Range: "observedAt"}},
+	)
+}
+
+// storeReadings stores hourly Tokyo records ending an hour before now, oldest first
+func storeReadings(t *testing.T, handler *handlers.DynamoDBHandler, now time.Time, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		record := newStoredReading("Tokyo", now.Add(time.Duration(i-count)*time.Hour), float64(20+i)).WeatherRecord
+		if err := handler.StoreWeatherRecord(&record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+}
+
+func TestDynamoDBStoreAndGet(t *testing.T) {
+	handler := handlers.NewDynamoDBHandlerFromClient(newFakeDynamoDB(), "weather")
+	record := newStoredReading("Tokyo", time.Now().UTC().Truncate(time.Second), 21.5).WeatherRecord
+
+	if replaced, err := handler.ReplaceWeatherRecord(&record); err != nil || replaced {
+		t.Fatalf("Expected replacing a missing record to be skipped, got %v, %v", replaced, err)
+	}
+	if err := handler.StoreWeatherRecord(&record); err != nil {
+		t.Fatalf("Failed to store record: %v", err)
+	}
+	got, err := handler.GetWeatherRecord(record.ID, record.Timestamp)
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
+	}
+	if got.CityName != "Tokyo" || got.Temperature != 21.5 || !got.CreatedAt.Equal(record.CreatedAt) {
+		t.Errorf("Expected the stored record back, got %+v", got)
+	}
+
+	record.Temperature = 22
+	if replaced, err := handler.ReplaceWeatherRecord(&record); err != nil || !replaced {
+		t.Fatalf("Expected the record to be replaced, got %v, %v", replaced, err)
+	}
+	if got, _ := handler.GetWeatherRecord(record.ID, record.Timestamp); got == nil || got.Temperature != 22 {
+		t.Errorf("Expected the replaced record, got %+v", got)
+	}
+
+	if _, err := handler.GetWeatherRecord("missing", record.Timestamp); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a missing record to be not found, got %v", err)
+	}
+}
+
+func TestDynamoDBQueryByCity(t *testing.T) {
+	handler := handlers.NewDynamoDBHandlerFromClient(newFakeDynamoDB(), "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	storeReadings(t, handler, now, 5)
+	expired := newStoredReading("Tokyo", now.Add(-40*24*time.Hour), 5).WeatherRecord
+	other := newStoredReading("Osaka", now.Add(-time.Hour), 25).WeatherRecord
+	for _, record := range []*models.WeatherRecord{&expired, &other} {
+		if err := handler.StoreWeatherRecord(record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+
+	records, err := handler.QueryWeatherRecordsByCity("Tokyo", 0)
+	if err != nil {
+		t.Fatalf("Failed to query records: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("Expected 5 unexpired Tokyo records, got %d", len(records))
+	}
+	if records[0].Temperature != 24 || records[4].Temperature != 20 {
+		t.Errorf("Expected records newest first, got %.0f first and %.0f last", records[0].Temperature, records[4].Temperature)
+	}
+
+	var temperatures []float64
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages == 5 {
+			t.Fatalf("Expected pagination to finish, still going after %d pages", pages)
+		}
+		page, err := handler.QueryWeatherRecordsByCityPage(context.Background(), "Tokyo", 2, token)
+		if err != nil {
+			t.Fatalf("Failed to query page: %v", err)
+		}
+		for _, record := range page.Records {
+			temperatures = append(temperatures, record.Temperature)
+		}
+		if token = page.NextToken; token == "" {
+			break
+		}
+		if _, err := handler.QueryWeatherRecordsByCityPage(context.Background(), "Osaka", 2, token); !errors.Is(err, errs.ErrValidation) {
+			t.Errorf("Expected a token replayed against another city to be invalid, got %v", err)
+		}
+	}
+	if len(temperatures) != 5 || temperatures[0] != 24 || temperatures[4] != 20 {
+		t.Errorf("Expected pages to cover every record once, newest first, got %v", temperatures)
+	}
+}
+
+func TestDynamoDBPagination(t *testing.T) {
+	handler := handlers.NewDynamoDBHandlerFromClient(newFakeDynamoDB(), "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	// Six readings with an expired one TTL hasn't deleted yet after every unexpired one
+	for i := 0; i < 6; i++ {
+		record := newStoredReading("Tokyo", now.Add(-time.Duration(i+1)*time.Hour), float64(30-i)).WeatherRecord
+		if i%2 == 1 {
+			record.TTL = now.Add(-time.Minute).Unix()
+		}
+		if err := handler.StoreWeatherRecord(&record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+
+	var temperatures []float64
+	var tokens []string
+	token := ""
+	for {
+		page, err := handler.QueryWeatherRecordsByCityPage(context.Background(), "Tokyo", 2, token)
+		if err != nil {
+			t.Fatalf("Failed to query page: %v", err)
+		}
+		if page.NextToken != "" && len(page.Records) != 2 {
+			t.Errorf("Expected expired records to be skipped without shortening the page, got %d records", len(page.Records))
+		}
+		for _, record := range page.Records {
+			temperatures = append(temperatures, record.Temperature)
+		}
+		if token = page.NextToken; token == "" {
+			break
+		}
+		tokens = append(tokens, token)
+	}
+	if len(temperatures) != 3 || temperatures[0] != 30 || temperatures[1] != 28 || temperatures[2] != 26 {
+		t.Errorf("Expected the unexpired records newest first, got %v", temperatures)
+	}
+
+	// A token resumes only the query that issued it
+	if len(tokens) == 0 {
+		t.Fatal("Expected at least one continuation token")
+	}
+	page, err := handler.QueryWeatherRecordsByCityPage(context.Background(), "Tokyo", 2, tokens[0])
+	if err != nil || len(page.Records) == 0 || page.Records[0].Temperature != 26 {
+		t.Errorf("Expected a token to resume after its page, got %+v, %v", page, err)
+	}
+	for _, bad := range []string{"not base64!", "e30", tokens[0][:len(tokens[0])-2]} {
+		if _, err := handler.QueryWeatherRecordsByCityPage(context.Background(), "Tokyo", 2, bad); !errors.Is(err, errs.ErrValidation) {
+			t.Errorf("Expected token %q to be invalid, got %v", bad, err)
+		}
+	}
+	if _, err := handler.QueryRecentWeatherRecordsPage(context.Background(), "Tokyo", 2, tokens[0]); !errors.Is(err, errs.ErrValidation) {
+		t.Errorf("Expected a city token replayed against an ID query to be invalid, got %v", err)
+	}
+
+	it := handler.IterateWeatherRecordsByCity(context.Background(), "Tokyo", 1)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if it.Err() != nil || count != 3 {
+		t.Errorf("Expected the iterator to yield 3 records, got %d, %v", count, it.Err())
+	}
+}
+
+func TestDynamoDBCityIndex(t *testing.T) {
+	fake := newFakeDynamoDB()
+	handler := handlers.NewDynamoDBHandlerFromClient(fake, "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	storeReadings(t, handler, now, 2)
+
+	// Items written before the index existed, and a provider cache entry, have no observedAt
+	legacy := newStoredReading("Tokyo", now.Add(-5*time.Hour), 15).WeatherRecord
+	for _, record := range []interface{}{legacy, map[string]string{"id": "cache#Tokyo", "timestamp": "latest"}} {
+		item, err := dynamodbattribute.MarshalMap(record)
+		if err != nil {
+			t.Fatalf("Failed to marshal item: %v", err)
+		}
+		if _, err := fake.PutItem(&dynamodb.PutItemInput{TableName: aws.String("weather"), Item: item}); err != nil {
+			t.Fatalf("Failed to put item: %v", err)
+		}
+	}
+	if records, err := handler.QueryWeatherRecordsByCity("Tokyo", 0); err != nil || len(records) != 2 {
+		t.Fatalf("Expected only the indexed records, got %d, %v", len(records), err)
+	}
+
+	result, err := handler.BackfillIndexAttributes(context.Background(), true, 2)
+	if err != nil || result.Scanned != 1 || result.Updated != 1 {
+		t.Fatalf("Expected a dry run to count the legacy record, got %+v, %v", result, err)
+	}
+	if records, _ := handler.QueryWeatherRecordsByCity("Tokyo", 0); len(records) != 2 {
+		t.Fatalf("Expected a dry run to leave the index alone, got %d records", len(records))
+	}
+	if result, err = handler.BackfillIndexAttributes(context.Background(), false, 2); err != nil || result.Updated != 1 {
+		t.Fatalf("Expected the legacy record to be backfilled, got %+v, %v", result, err)
+	}
+	records, err := handler.GetWeatherHistory(context.Background(), "Tokyo", now.Add(-6*time.Hour), now)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(records) != 3 || records[0].Temperature != 15 {
+		t.Errorf("Expected the backfilled record first in the index, got %+v", records)
+	}
+	if result, _ = handler.BackfillIndexAttributes(context.Background(), false, 2); result.Scanned != 0 {
+		t.Errorf("Expected a rerun to find nothing to backfill, got %+v", result)
+	}
+}
+
+func TestDynamoDBHistory(t *testing.T) {
+	handler := handlers.NewDynamoDBHandlerFromClient(newFakeDynamoDB(), "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	storeReadings(t, handler, now, 6)
+
+	records, err := handler.GetWeatherHistory(context.Background(), "Tokyo", now.Add(-4*time.Hour), now.Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(records) != 3 || records[0].Temperature != 22 || records[2].Temperature != 24 {
+		t.Errorf("Expected the three records in range, oldest first, got %+v", records)
+	}
+
+	s3Handler := handlers.NewS3HandlerFromStore(handlers.NewS3BlobStore(awsfake.NewS3(), "archive"))
+	archived := newStoredReading("Tokyo", now.Add(-10*24*time.Hour), 12)
+	if err := s3Handler.StoreWeatherData(archived); err != nil {
+		t.Fatalf("Failed to archive reading: %v", err)
+	}
+	service := history.NewService(handler, s3Handler, history.FixedRetention(7*24*time.Hour), 2)
+	result, err := service.Query(context.Background(), "Tokyo", now.Add(-12*24*time.Hour), now)
+	if err != nil {
+		t.Fatalf("Failed to query history service: %v", err)
+	}
+	if len(result.Records) != 7 || result.Records[0].Temperature != 12 {
+		t.Errorf("Expected the archived reading followed by 6 records, got %d records", len(result.Records))
+	}
+	if len(result.Sources) != 2 || result.Sources[0] != history.SourceArchive || result.Sources[1] != history.SourceDynamoDB {
+		t.Errorf("Expected archive and DynamoDB sources, got %v", result.Sources)
+	}
+}
+
+func TestDynamoDBLatest(t *testing.T) {
+	handler := handlers.NewDynamoDBHandlerFromClient(newFakeDynamoDB(), "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	newer := newStoredReading("Tokyo", now, 22).WeatherRecord
+	older := newStoredReading("Tokyo", now.Add(-time.Hour), 18).WeatherRecord
+
+	if updated, err := handler.PutLatestWeather(context.Background(), &newer); err != nil || !updated {
+		t.Fatalf("Expected the first reading to become the latest, got %v, %v", updated, err)
+	}
+	if updated, err := handler.PutLatestWeather(context.Background(), &older); err != nil || updated {
+		t.Fatalf("Expected an older reading to be ignored, got %v, %v", updated, err)
+	}
+	latest, err := handler.GetLatestWeather(context.Background(), "Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to get latest reading: %v", err)
+	}
+	if latest.Temperature != 22 {
+		t.Errorf("Expected the newest reading, got %+v", latest)
+	}
+	if _, err := handler.GetLatestWeather(context.Background(), "Osaka"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a city without readings to be not found, got %v", err)
+	}
+}
+
+func TestDynamoDBObservationTime(t *testing.T) {
+	handler := handlers.NewDynamoDBHandlerFromClient(newFakeDynamoDB(), "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	// A late collection of an old observation, then a prompt collection of a newer one
+	late := newStoredReading("Tokyo", now, 18).WeatherRecord
+	late.ObservedAt = now.Add(-50 * time.Minute).Unix()
+	prompt := newStoredReading("Tokyo", now.Add(-10*time.Minute), 22).WeatherRecord
+	prompt.ObservedAt = now.Add(-15 * time.Minute).Unix()
+	for _, record := range []*models.WeatherRecord{&late, &prompt} {
+		if err := handler.StoreWeatherRecord(record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+
+	records, err := handler.GetWeatherHistory(context.Background(), "Tokyo", now.Add(-time.Hour), now.Add(-30*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(records) != 1 || records[0].Temperature != 18 {
+		t.Errorf("Expected the range to select by observation time, got %+v", records)
+	}
+	if recent, _ := handler.QueryWeatherRecordsByCity("Tokyo", 1); len(recent) != 1 || recent[0].Temperature != 22 {
+		t.Errorf("Expected the latest observation first, got %+v", recent)
+	}
+
+	if _, err := handler.PutLatestWeather(context.Background(), &prompt); err != nil {
+		t.Fatalf("Failed to put latest reading: %v", err)
+	}
+	if updated, err := handler.PutLatestWeather(context.Background(), &late); err != nil || updated {
+		t.Errorf("Expected a later collection of an older observation to be ignored, got %v, %v", updated, err)
+	}
+}
+
+func TestDynamoDBFailures(t *testing.T) {
+	fake := newFakeDynamoDB()
+	handler := handlers.NewDynamoDBHandlerFromClient(fake, "weather")
+	record := newStoredReading("Tokyo", time.Now().UTC().Truncate(time.Second), 20).WeatherRecord
+
+	fake.Fail = func(operation string) error { return awsfake.ThrottlingError() }
+	if err := handler.StoreWeatherRecord(&record); !errors.Is(err, errs.ErrThrottled) {
+		t.Errorf("Expected a throttled put to be classified as throttled, got %v", err)
+	}
+	fake.Fail = func(operation string) error { return awsfake.UnavailableError() }
+	if _, err := handler.QueryWeatherRecordsByCity("Tokyo", 10); !errors.Is(err, errs.ErrUpstreamUnavailable) {
+		t.Errorf("Expected a 503 to be classified as upstream unavailable, got %v", err)
+	}
+	fake.Fail = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := handler.GetWeatherHistory(ctx, "Tokyo", time.Now().Add(-time.Hour), time.Now()); err == nil {
+		t.Error("Expected a canceled query to fail")
+	}
+}
+
+func TestDynamoDBParallelScan(t *testing.T) {
+	fake := newFakeDynamoDB()
+	handler := handlers.NewDynamoDBHandlerFromClient(fake, "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	storeReadings(t, handler, now, 40)
+
+	// Throttled requests are retried after a shared backoff instead of failing the scan
+	var mu sync.Mutex
+	scans := 0
+	fake.Fail = func(operation string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if operation == "Scan" {
+			if scans++; scans <= 2 {
+				return awsfake.ThrottlingError()
+			}
+		}
+		return nil
+	}
+	start := time.Now()
+	records, scan := handler.ScanWeatherRecords(context.Background(), handlers.ScanOptions{Segments: 4, PageSize: 5})
+	count := 0
+	for range records {
+		count++
+	}
+	if err := scan.Err(); err != nil || count != 40 {
+		t.Fatalf("Expected every record despite throttling, got %d, %v", count, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected throttling to pause the scan, finished in %s", elapsed)
+	}
+
+	// A segment that fails outright stops the others, since the scan can't be complete
+	scans = 0
+	fake.Fail = func(operation string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if operation == "Scan" {
+			if scans++; scans == 3 {
+				return awsfake.UnavailableError()
+			}
+		}
+		return nil
+	}
+	scanned := handler.ScanTable(context.Background(), handlers.ScanOptions{Segments: 4, PageSize: 1})
+	count = 0
+	for range scanned.Items {
+		count++
+	}
+	if err := scanned.Err(); !errors.Is(err, errs.ErrUpstreamUnavailable) {
+		t.Errorf("Expected the segment failure to be reported, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if count >= 40 || scans >= 40 {
+		t.Errorf("Expected the other segments to stop early, got %d items from %d requests", count, scans)
+	}
+}
+
+func TestDynamoDBBatchWrite(t *testing.T) {
+	fake := newFakeDynamoDB()
+	fake.BatchWriteLimit = 10 // Each call leaves all but 10 items unprocessed
+	handler := handlers.NewDynamoDBHandlerFromClient(fake, "weather")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	records := make([]*models.WeatherRecord, 30)
+	for i := range records {
+		record := newStoredReading("Tokyo", now.Add(-time.Duration(i)*time.Minute), 20).WeatherRecord
+		records[i] = &record
+	}
+
+	result := handler.BatchWriteWeatherRecords(context.Background(), records, handlers.BatchWriteOptions{Concurrency: 1})
+	if result.Written != 30 || len(result.Failed) != 0 {
+		t.Fatalf("Expected every record written after retries, got %d written and %d failed", result.Written, len(result.Failed))
+	}
+	if items := fake.Items("weather"); len(items) != 30 {
+		t.Errorf("Expected 30 items in the table, got %d", len(items))
+	}
+
+	fake.BatchWriteLimit = 0
+	fake.Fail = func(operation string) error { return awsfake.UnavailableError() }
+	result = handler.BatchWriteWeatherRecords(context.Background(), records[:5], handlers.BatchWriteOptions{})
+	if len(result.Failed) != 5 || !errors.Is(result.Failed[0].Err, errs.ErrUpstreamUnavailable) {
+		t.Errorf("Expected every record to fail as upstream unavailable, got %+v", result.Failed)
+	}
+}
+
+func TestDynamoDBBatchWriteRetries(t *testing.T) {
+	fake := newFakeDynamoDB()
+	handler := handlers.NewDynamoDBHandlerFromClient(fake, "weather")
+	now := time.Now().UTC().Truncate(time.Second)
+	records := make([]*models.WeatherRecord, 30)
+	for i := range records {
+		record := newStoredReading("Tokyo", now.Add(-time.Duration(i)*time.Minute), float64(i)).WeatherRecord
+		records[i] = &record
+	}
+
+	// Items still unprocessed after the last attempt are reported, not dropped
+	fake.BatchWriteLimit = 10
+	result := handler.BatchWriteWeatherRecords(context.Background(), records, handlers.BatchWriteOptions{Concurrency: 1, MaxAttempts: 2})
+	if result.Written != 25 || len(result.Failed) != 5 {
+		t.Fatalf("Expected 25 written and 5 failed, got %d written and %d failed", result.Written, len(result.Failed))
+	}
+	for _, failure := range result.Failed {
+		if failure.ID == "" || failure.Timestamp == "" || !errors.Is(failure.Err, errs.ErrThrottled) {
+			t.Errorf("Expected a throttled failure naming its record, got %+v", failure)
+		}
+	}
+	if items := fake.Items("weather"); len(items) != 25 {
+		t.Errorf("Expected 25 items in the table, got %d", len(items))
+	}
+	var batchErr *handlers.BatchWriteError
+	fake.BatchWriteLimit = 0
+	fake.Fail = func(operation string) error { return awsfake.UnavailableError() }
+	if err := handler.BatchStoreWeatherRecords(context.Background(), records[:3]); !errors.As(err, &batchErr) || len(batchErr.Failures) != 3 {
+		t.Errorf("Expected a BatchWriteError listing 3 records, got %v", err)
+	}
+
+	// A throttled call is retried whole
+	calls := 0
+	fake.Fail = func(operation string) error {
+		if operation == "BatchWriteItem" {
+			if calls++; calls == 1 {
+				return awsfake.ThrottlingError()
+			}
+		}
+		return nil
+	}
+	if result = handler.BatchWriteWeatherRecords(context.Background(), records[:3], handlers.BatchWriteOptions{}); result.Written != 3 || len(result.Failed) != 0 || calls != 2 {
+		t.Errorf("Expected the throttled call to succeed on retry, got %+v after %d calls", result, calls)
+	}
+	fake.Fail = nil
+
+	// Records sharing a key are collapsed to the last, as successive puts would leave them
+	duplicate := *records[0]
+	duplicate.Temperature = 99
+	result = handler.BatchWriteWeatherRecords(context.Background(), []*models.WeatherRecord{records[0], records[1], &duplicate}, handlers.BatchWriteOptions{})
+	if result.Written != 3 || len(result.Failed) != 0 {
+		t.Fatalf("Expected every record counted as written, got %+v", result)
+	}
+	if got, err := handler.GetWeatherRecord(duplicate.ID, duplicate.Timestamp); err != nil || got.Temperature != 99 {
+		t.Errorf("Expected the last duplicate to win, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/weather-lambda/internal/api"
+	"github.com/weather-lambda/internal/awsfake"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/services"
+	"github.com/weather-lambda/internal/storage"
+)
+
+func TestErrorTaxonomy(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		code   string
+		status int
+	}{
+		{"StoreNotFound", fmt.Errorf("weather record not found: %w", storage.ErrNotFound), errs.CodeNotFound, http.StatusNotFound},
+		{"Validation", fmt.Errorf("bad request: %w", errs.Invalid("start", "invalid start")), errs.CodeValidation, http.StatusBadRequest},
+		{"Marked", errs.Mark(errors.New("ConditionalCheckFailedException"), errs.ErrConflict), errs.CodeConflict, http.StatusConflict},
+		{"UpstreamRateLimited", &errs.UpstreamError{StatusCode: http.StatusTooManyRequests, Err: errors.New("429")}, errs.CodeThrottled, http.StatusTooManyRequests},
+		{"UpstreamDown", &errs.UpstreamError{StatusCode: http.StatusBadGateway, Err: errors.New("502")}, errs.CodeUpstreamUnavailable, http.StatusServiceUnavailable},
+		{"Unclassified", errors.New("boom"), errs.CodeInternal, http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := errs.Code(tc.err); code != tc.code {
+				t.Errorf("Expected code %s, got %s", tc.code, code)
+			}
+			if status := errs.HTTPStatus(tc.err); status != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, status)
+			}
+		})
+	}
+
+	if err := errs.Mark(errors.New("original"), errs.ErrThrottled); err.Error() != "original" {
+		t.Errorf("Expected Mark to keep the message, got %q", err.Error())
+	}
+}
+
+func TestAWSErrorClasses(t *testing.T) {
+	missingTable := newFakeDynamoDB()
+	missingTable.Fail = func(string) error {
+		return awserr.NewRequestFailure(awserr.New(dynamodb.ErrCodeResourceNotFoundException, "requested resource not found", nil), http.StatusBadRequest, "fake-request")
+	}
+	missingBucket := awsfake.NewS3()
+	missingBucket.Fail = func(string) error {
+		return awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchBucket, "the specified bucket does not exist", nil), http.StatusNotFound, "fake-request")
+	}
+	_, tableErr := handlers.NewDynamoDBHandlerFromClient(missingTable, "weather").GetWeatherRecord("Tokyo-1", "2024-03-15T00:00:00Z")
+	_, itemErr := handlers.NewDynamoDBHandlerFromClient(newFakeDynamoDB(), "weather").GetWeatherRecord("Tokyo-1", "2024-03-15T00:00:00Z")
+	_, bucketErr := handlers.NewS3BlobStore(missingBucket, "weather").Get(context.Background(), "weather-data/missing.json")
+	_, keyErr := handlers.NewS3BlobStore(awsfake.NewS3(), "weather").Get(context.Background(), "weather-data/missing.json")
+
+	cases := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"MissingTable", tableErr, http.StatusInternalServerError},
+		{"MissingBucket", bucketErr, http.StatusInternalServerError},
+		{"MissingItem", itemErr, http.StatusNotFound},
+		{"MissingKey", keyErr, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.err == nil {
+				t.Fatal("Expected an error")
+			}
+			if status := errs.HTTPStatus(tc.err); status != tc.status {
+				t.Errorf("Expected status %d, got %d for %v", tc.status, status, tc.err)
+			}
+		})
+	}
+}
+
+func TestProviderErrorClasses(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	service := services.NewWeatherService(&config.Config{
+		Weather: config.WeatherConfig{APIKey: "test-key", APIURL: server.URL, CityName: "Tokyo"},
+	})
+
+	if _, err := service.FetchWeatherData("Tokyo"); !errors.Is(err, errs.ErrUpstreamUnavailable) {
+		t.Errorf("Expected a 503 to be upstream unavailable, got %v", err)
+	}
+	status = http.StatusTooManyRequests
+	if _, err := service.FetchWeatherData("Tokyo"); !errors.Is(err, errs.ErrThrottled) {
+		t.Errorf("Expected a 429 to be throttled, got %v", err)
+	}
+	status = http.StatusNotFound
+	if _, err := service.FetchWeatherData("Atlantis"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a 404 to be not found, got %v", err)
+	}
+}
+
+func TestAPIErrorCodes(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Weather.CityName = "Tokyo"
+	stores, err := handlers.NewStores(cfg, config.StorageConfig{Backend: config.StorageMemory})
+	if err != nil {
+		t.Fatalf("Failed to create stores: %v", err)
+	}
+	handler := api.NewHandler(cfg, stores)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		query  map[string]string
+		status int
+		code   string
+	}{
+		{"InvalidRange", http.MethodGet, "/weather/history", map[string]string{"start": "yesterday"}, http.StatusBadRequest, errs.CodeValidation},
+		{"InvalidPeriod", http.MethodGet, "/weather/history", map[string]string{"period": "forever"}, http.StatusBadRequest, errs.CodeValidation},
+		{"NoReadings", http.MethodGet, "/weather/current", nil, http.StatusNotFound, errs.CodeNotFound},
+		{"WrongMethod", http.MethodPost, "/weather/history", nil, http.StatusMethodNotAllowed, api.CodeMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := handler.HandleRequest(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            tc.method,
+				Path:                  tc.path,
+				Headers:               map[string]string{"x-api-key": "secret"},
+				QueryStringParameters: tc.query,
+			})
+			if err != nil {
+				t.Fatalf("Failed to handle request: %v", err)
+			}
+			var body api.ErrorResponse
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to decode error response %q: %v", response.Body, err)
+			}
+			if response.StatusCode != tc.status || body.Code != tc.code || body.Error == "" {
+				t.Errorf("Expected %d %s, got %d %+v", tc.status, tc.code, response.StatusCode, body)
+			}
+		})
+	}
+}
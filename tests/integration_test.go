@@ -36,7 +36,10 @@ func TestAWSIntegration(t *testing.T) {
 
 	// Initialize handlers
 	s3Handler := handlers.NewS3Handler(cfg, sess)
-	dynamoDBHandler := handlers.NewDynamoDBHandler(cfg, sess)
+	dynamoDBHandler, err := handlers.NewDynamoDBHandler(cfg, sess)
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB handler: %v", err)
+	}
 
 	t.Run("TestS3Handler_AWS", func(t *testing.T) {
 		// Create test data
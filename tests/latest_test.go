@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/weather-lambda/internal/api"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+)
+
+func TestCurrentConditionsEndpoint(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Weather.CityName = "Tokyo"
+	stores, err := handlers.NewStores(cfg, config.StorageConfig{Backend: config.StorageMemory})
+	if err != nil {
+		t.Fatalf("Failed to create stores: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, reading := range []struct {
+		age         time.Duration
+		temperature float64
+	}{{2 * time.Hour, 15}, {10 * time.Minute, 18}, {time.Hour, 16}} {
+		record := newStoredReading("Tokyo", now.Add(-reading.age), reading.temperature).WeatherRecord
+		if err := stores.Records.StoreWeatherRecord(&record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+
+	handler := api.NewHandler(cfg, stores)
+	get := func(city string) events.APIGatewayProxyResponse {
+		response, err := handler.HandleRequest(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod:            http.MethodGet,
+			Path:                  "/weather/current",
+			Headers:               map[string]string{"x-api-key": "secret"},
+			QueryStringParameters: map[string]string{"city": city},
+		})
+		if err != nil {
+			t.Fatalf("Failed to handle request: %v", err)
+		}
+		return response
+	}
+
+	response := get("")
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", response.StatusCode, response.Body)
+	}
+	var body api.CurrentConditionsResponse
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.City != "Tokyo" || body.Data == nil || body.Data.Temperature != 18 {
+		t.Errorf("Expected the newest Tokyo reading, got %+v", body)
+	}
+
+	if response := get("London"); response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a city without readings, got %d: %s", response.StatusCode, response.Body)
+	}
+}
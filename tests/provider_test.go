@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/fakeprovider"
+	"github.com/weather-lambda/internal/services"
+)
+
+func newFakeProviderService(t *testing.T, provider *fakeprovider.Server) *services.WeatherService {
+	t.Helper()
+
+	server := httptest.NewServer(provider)
+	t.Cleanup(server.Close)
+
+	return services.NewWeatherService(&config.Config{
+		Weather: config.WeatherConfig{
+			APIKey:   "test-key",
+			APIURL:   server.URL + "/data/2.5/weather",
+			CityName: "Tokyo",
+		},
+	})
+}
+
+func TestFakeProvider(t *testing.T) {
+	fixedNow := time.Date(2026, 10, 16, 5, 3, 0, 0, time.UTC)
+	clock := func() time.Time { return fixedNow }
+
+	t.Run("TestDeterministicResponse", func(t *testing.T) {
+		provider, err := fakeprovider.NewServer(fakeprovider.ModeDeterministic, fakeprovider.WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create fake provider: %v", err)
+		}
+		service := newFakeProviderService(t, provider)
+
+		first, err := service.GetWeatherData()
+		if err != nil {
+			t.Fatalf("Failed to fetch weather data: %v", err)
+		}
+		second, err := service.GetWeatherData()
+		if err != nil {
+			t.Fatalf("Failed to fetch weather data: %v", err)
+		}
+
+		if first.Name != "Tokyo" {
+			t.Errorf("Expected city name Tokyo, got %s", first.Name)
+		}
+		if first.Dt != fixedNow.Truncate(fakeprovider.ObservationInterval).Unix() {
+			t.Errorf("Expected dt aligned to the observation interval, got %d", first.Dt)
+		}
+		if first.Main.Temp != second.Main.Temp || first.Dt != second.Dt {
+			t.Errorf("Expected identical responses, got %+v and %+v", first.Main, second.Main)
+		}
+	})
+
+	t.Run("TestScriptedErrors", func(t *testing.T) {
+		provider, err := fakeprovider.NewServer(fakeprovider.ModeDeterministic,
+			fakeprovider.WithClock(clock),
+			fakeprovider.WithScript(
+				fakeprovider.Step{Status: http.StatusTooManyRequests},
+				fakeprovider.Step{Malformed: true},
+			),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create fake provider: %v", err)
+		}
+		service := newFakeProviderService(t, provider)
+
+		if _, err := service.GetWeatherData(); err == nil {
+			t.Error("Expected an error for a 429 response")
+		}
+		if _, err := service.GetWeatherData(); err == nil {
+			t.Error("Expected an error for malformed JSON")
+		}
+		if _, err := service.GetWeatherData(); err != nil {
+			t.Errorf("Expected the default response after the script, got %v", err)
+		}
+	})
+
+	t.Run("TestUnchangedObservation", func(t *testing.T) {
+		provider, err := fakeprovider.NewServer(fakeprovider.ModeDeterministic, fakeprovider.WithClock(clock))
+		if err != nil {
+			t.Fatalf("Failed to create fake provider: %v", err)
+		}
+		service := newFakeProviderService(t, provider)
+
+		first, err := service.FetchWeatherData("Tokyo")
+		if err != nil {
+			t.Fatalf("Failed to fetch weather data: %v", err)
+		}
+		if !first.Changed {
+			t.Error("Expected the first observation to be reported as changed")
+		}
+		if err := service.RememberResponse(first); err != nil {
+			t.Fatalf("Failed to remember response: %v", err)
+		}
+
+		second, err := service.FetchWeatherData("Tokyo")
+		if err != nil {
+			t.Fatalf("Failed to fetch weather data: %v", err)
+		}
+		if second.Changed || !second.NotModified {
+			t.Errorf("Expected a 304 for the same observation, got changed=%t notModified=%t", second.Changed, second.NotModified)
+		}
+	})
+
+	t.Run("TestReplayFixtures", func(t *testing.T) {
+		dir := t.TempDir()
+		fixtures := fakeprovider.NewFixtureStore(dir)
+		if err := fixtures.Save("Osaka", []byte(`{"name":"Osaka","main":{"temp":18.5},"dt":1700000000}`)); err != nil {
+			t.Fatalf("Failed to save fixture: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "osaka.json")); err != nil {
+			t.Fatalf("Expected fixture file: %v", err)
+		}
+
+		provider, err := fakeprovider.NewServer(fakeprovider.ModeReplay, fakeprovider.WithFixtures(dir))
+		if err != nil {
+			t.Fatalf("Failed to create fake provider: %v", err)
+		}
+		service := newFakeProviderService(t, provider)
+
+		result, err := service.FetchWeatherData("Osaka")
+		if err != nil {
+			t.Fatalf("Failed to replay fixture: %v", err)
+		}
+		if result.Response.Main.Temp != 18.5 {
+			t.Errorf("Expected temperature 18.5, got %f", result.Response.Main.Temp)
+		}
+		if _, err := service.FetchWeatherData("Nagoya"); err == nil {
+			t.Error("Expected an error for a city without a fixture")
+		}
+	})
+}
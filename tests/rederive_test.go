@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/jobs"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/services"
+)
+
+func TestRederiveWeatherRecord(t *testing.T) {
+	service := services.NewWeatherService(&config.Config{})
+	collected := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	archived := &models.S3WeatherData{
+		WeatherRecord: models.WeatherRecord{
+			ID:          "Tokyo-1705314600",
+			Timestamp:   collected.Format(time.RFC3339),
+			CityName:    "Tokyo",
+			Temperature: 1.0, // Stale derivation, replaced from the raw response
+			CreatedAt:   collected,
+		},
+		RawResponse: models.WeatherResponse{
+			Name:    "Tokyo",
+			Main:    models.Main{Temp: 8.5, Humidity: 40, Pressure: 1018},
+			Weather: []models.Weather{{Main: "Clear", Description: "clear sky"}},
+			Wind:    models.Wind{Speed: 3.2},
+			Sys:     models.Sys{Country: "JP"},
+			Dt:      collected.Add(-5 * time.Minute).Unix(),
+		},
+	}
+
+	record := service.RederiveWeatherRecord(archived)
+
+	if record.ID != archived.ID || record.Timestamp != archived.Timestamp {
+		t.Errorf("Expected identity %s/%s to be kept, got %s/%s", archived.ID, archived.Timestamp, record.ID, record.Timestamp)
+	}
+	if !record.CreatedAt.Equal(collected) {
+		t.Errorf("Expected CreatedAt %v, got %v", collected, record.CreatedAt)
+	}
+	if want := collected.Add(30 * 24 * time.Hour).Unix(); record.TTL != want {
+		t.Errorf("Expected TTL %d relative to collection time, got %d", want, record.TTL)
+	}
+	if record.Temperature != 8.5 || record.Description != "clear sky" || record.Country != "JP" {
+		t.Errorf("Expected fields re-derived from the raw response, got %+v", record)
+	}
+}
+
+func TestDiffWeatherRecords(t *testing.T) {
+	created := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	stored := &models.WeatherRecord{ID: "Tokyo-1", CityName: "Tokyo", Temperature: 1.0, Description: "clear sky", CreatedAt: created}
+	derived := *stored
+	derived.Temperature = 8.5
+	derived.CreatedAt = created.In(time.FixedZone("JST", 9*60*60)) // Same instant, different zone
+
+	changes := jobs.DiffWeatherRecords(stored, &derived)
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %+v", changes)
+	}
+	if changes[0].Field != "temperature" || changes[0].Old != 1.0 || changes[0].New != 8.5 {
+		t.Errorf("Unexpected change: %+v", changes[0])
+	}
+
+	if changes := jobs.DiffWeatherRecords(stored, stored); len(changes) != 0 {
+		t.Errorf("Expected no changes for identical records, got %+v", changes)
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/awsfake"
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/jobs"
+	"github.com/weather-lambda/internal/services"
+	"github.com/weather-lambda/internal/storage"
+)
+
+func TestRehydrateResumeCounts(t *testing.T) {
+	s3Handler := handlers.NewS3HandlerFromStore(storage.NewMemoryBlobStore(), handlers.WithKeyLayout(handlers.KeyLayoutHive))
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := s3Handler.StoreWeatherData(newStoredReading("Tokyo", day.Add(time.Duration(i+1)*time.Hour), float64(20+i))); err != nil {
+			t.Fatalf("Failed to store weather data: %v", err)
+		}
+	}
+
+	fake := newFakeDynamoDB()
+	writes := 0
+	fake.Fail = func(operation string) error {
+		if operation == "BatchWriteItem" {
+			if writes++; writes > 1 {
+				return awsfake.UnavailableError()
+			}
+		}
+		return nil
+	}
+	rehydrator := jobs.NewRehydrator(s3Handler, handlers.NewDynamoDBHandlerFromClient(fake, "weather"),
+		services.NewWeatherService(&config.Config{}), jobs.NewCheckpointStore(s3Handler, jobs.DefaultCheckpointPrefix, "rehydrate"))
+	opts := jobs.RehydrateOptions{City: "Tokyo", Start: day, End: day.Add(24 * time.Hour), BatchSize: 2}
+
+	report, err := rehydrator.Run(context.Background(), "job", opts)
+	if err == nil {
+		t.Fatal("Expected the second batch to fail")
+	}
+	if report.Written != 2 || report.Job.Processed != 2 || report.Job.Written != 2 {
+		t.Fatalf("Expected the checkpoint to cover the first batch only, got %+v, job %+v", report, report.Job)
+	}
+
+	fake.Fail = nil
+	if report, err = rehydrator.Run(context.Background(), "job", opts); err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+	if !report.Resumed || report.Processed != 3 || report.Written != 3 {
+		t.Errorf("Expected the resumed run to count only the remaining 3 objects, got %+v", report)
+	}
+	if !report.Job.Done || report.Job.Processed != 5 || report.Job.Written != 5 {
+		t.Errorf("Expected job totals of 5, got %+v", report.Job)
+	}
+	if items := fake.Items("weather"); len(items) != 5 {
+		t.Errorf("Expected 5 records in the table, got %d", len(items))
+	}
+
+	if report, err = rehydrator.Run(context.Background(), "job", opts); err != nil || report.Processed != 0 {
+		t.Errorf("Expected a completed job to do no work, got %+v, %v", report, err)
+	}
+}
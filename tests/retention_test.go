@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/config"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/services"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "prod")
+	t.Setenv("RECORD_RETENTION_DAYS", "30")
+	t.Setenv("RECORD_RETENTION_ENVIRONMENT_DAYS", "dev=7, prod=90, staging=oops")
+	t.Setenv("RECORD_RETENTION_CITY_DAYS", "Tokyo=365,London=0")
+	retention := config.LoadRetention()
+
+	day := 24 * time.Hour
+	tests := []struct {
+		city string
+		want time.Duration
+	}{
+		{"Tokyo", 365 * day},
+		{"London", 0},
+		{"Paris", 90 * day},
+	}
+	for _, tt := range tests {
+		if got := retention.For(tt.city); got != tt.want {
+			t.Errorf("%s: expected retention %s, got %s", tt.city, tt.want, got)
+		}
+	}
+
+	retention.Environment = "staging"
+	if got := retention.For("Paris"); got != 30*day {
+		t.Errorf("Expected an invalid environment override to fall back to the default, got %s", got)
+	}
+
+	collected := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	if got := retention.ExpiresAt("London", collected); got != 0 {
+		t.Errorf("Expected a keep-forever city to have no TTL, got %d", got)
+	}
+
+	service := services.NewWeatherService(&config.Config{})
+	service.SetRetention(retention)
+	record := service.RederiveWeatherRecord(&models.S3WeatherData{
+		WeatherRecord: models.WeatherRecord{CreatedAt: collected},
+		RawResponse:   models.WeatherResponse{Name: "Tokyo"},
+	})
+	if want := collected.Add(365 * day).Unix(); record.TTL != want {
+		t.Errorf("Expected record TTL %d, got %d", want, record.TTL)
+	}
+}
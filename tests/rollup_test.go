@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/history"
+	"github.com/weather-lambda/internal/jobs"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+func TestDailySummaryHistory(t *testing.T) {
+	archive := handlers.NewS3HandlerFromStore(storage.NewMemoryBlobStore(), handlers.WithKeyLayout(handlers.KeyLayoutHive))
+	summaries := handlers.NewS3SummaryStore(archive, handlers.DefaultSummaryPrefix)
+	records := storage.NewMemoryRecordStore()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	day := now.Add(-45 * 24 * time.Hour).Truncate(24 * time.Hour)
+	readings := []struct {
+		offset        time.Duration
+		temperature   float64
+		precipitation float64
+	}{
+		{6 * time.Hour, 5, 0.5},
+		{6*time.Hour + 30*time.Minute, 7, 0.8}, // Same hour: only the larger amount counts
+		{14 * time.Hour, 12, 0.2},
+	}
+	for _, r := range readings {
+		reading := newStoredReading("Tokyo", day.Add(r.offset), r.temperature)
+		reading.TTL = 0 // Still readable when the rollup runs
+		reading.Precipitation = r.precipitation
+		if err := records.StoreWeatherRecord(&reading.WeatherRecord); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+
+	summary, err := jobs.NewRollup(records, summaries).RollupDay(context.Background(), "Tokyo", day)
+	if err != nil {
+		t.Fatalf("Failed to roll up day: %v", err)
+	}
+	if summary.Readings != 3 || summary.TemperatureMin != 5 || summary.TemperatureMax != 12 ||
+		summary.TemperatureMean != 8 || summary.Precipitation != 1 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if empty, err := jobs.NewRollup(records, summaries).RollupDay(context.Background(), "Tokyo", day.Add(24*time.Hour)); err != nil || empty != nil {
+		t.Errorf("Expected no summary for a day without records, got %+v, %v", empty, err)
+	}
+
+	// A reading older than the first summary is still read from the archive
+	older := newStoredReading("Tokyo", day.Add(-10*24*time.Hour), 3)
+	if err := archive.StoreWeatherData(older); err != nil {
+		t.Fatalf("Failed to archive weather data: %v", err)
+	}
+	recent := newStoredReading("Tokyo", now.Add(-2*time.Hour), 18)
+	if err := records.StoreWeatherRecord(&recent.WeatherRecord); err != nil {
+		t.Fatalf("Failed to store record: %v", err)
+	}
+
+	service := history.NewService(records, archive, history.FixedRetention(30*24*time.Hour), 4)
+	service.SetSummaries(summaries)
+	result, err := service.Query(context.Background(), "Tokyo", now.Add(-60*24*time.Hour), now)
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	if len(result.Summaries) != 1 || result.Summaries[0].Date != day.Format("2006-01-02") {
+		t.Errorf("Expected the rolled-up day, got %+v", result.Summaries)
+	}
+	if len(result.Records) != 2 || result.Records[0].ID != older.ID || result.Records[1].ID != recent.ID {
+		t.Errorf("Expected the archived and recent readings only, got %+v", recordIDs(result.Records))
+	}
+	want := []string{history.SourceSummaries, history.SourceArchive, history.SourceDynamoDB}
+	if len(result.Sources) != len(want) {
+		t.Errorf("Expected sources %v, got %v", want, result.Sources)
+	}
+}
+
+func TestSummarizeDayObservationHours(t *testing.T) {
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	// Both readings were observed in the 23:00 hour, though the second was collected after midnight
+	first := newStoredReading("Tokyo", day.Add(23*time.Hour+25*time.Minute), 10).WeatherRecord
+	first.ObservedAt = day.Add(23*time.Hour + 20*time.Minute).Unix()
+	first.Precipitation = 0.6
+	second := newStoredReading("Tokyo", day.Add(24*time.Hour+5*time.Minute), 9).WeatherRecord
+	second.ObservedAt = day.Add(23*time.Hour + 50*time.Minute).Unix()
+	second.Precipitation = 0.9
+
+	summary := jobs.SummarizeDay("Tokyo", day, []models.WeatherRecord{first, second})
+	if summary.Precipitation != 0.9 {
+		t.Errorf("Expected one hour of precipitation by observation time, got %.2f", summary.Precipitation)
+	}
+
+	records := storage.NewMemoryRecordStore()
+	for _, record := range []*models.WeatherRecord{&first, &second} {
+		record.TTL = 0
+		if err := records.StoreWeatherRecord(record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+	summaries := handlers.NewS3SummaryStore(handlers.NewS3HandlerFromStore(storage.NewMemoryBlobStore()), handlers.DefaultSummaryPrefix)
+	if summary, err := jobs.NewRollup(records, summaries).RollupDay(context.Background(), "Tokyo", day); err != nil || summary == nil || summary.Readings != 2 {
+		t.Errorf("Expected both readings in the day they were observed, got %+v, %v", summary, err)
+	}
+}
+
+func TestDailySummaryGaps(t *testing.T) {
+	archive := handlers.NewS3HandlerFromStore(storage.NewMemoryBlobStore(), handlers.WithKeyLayout(handlers.KeyLayoutHive))
+	summaries := handlers.NewS3SummaryStore(archive, handlers.DefaultSummaryPrefix)
+	now := time.Now().UTC().Truncate(time.Second)
+	day := now.Add(-45 * 24 * time.Hour).Truncate(24 * time.Hour)
+
+	// The rollup missed the middle day of three
+	for _, d := range []time.Time{day, day.AddDate(0, 0, 2)} {
+		if err := summaries.PutDailySummary(context.Background(), &models.DailySummary{City: "Tokyo", Date: d.Format("2006-01-02"), Readings: 1}); err != nil {
+			t.Fatalf("Failed to put summary: %v", err)
+		}
+	}
+	missed := newStoredReading("Tokyo", day.Add(36*time.Hour), 10)
+	summarized := newStoredReading("Tokyo", day.Add(60*time.Hour), 11)
+	for _, reading := range []*models.S3WeatherData{missed, summarized} {
+		if err := archive.StoreWeatherData(reading); err != nil {
+			t.Fatalf("Failed to archive weather data: %v", err)
+		}
+	}
+	start, end := day, day.AddDate(0, 0, 3).Add(-time.Second)
+
+	service := history.NewService(storage.NewMemoryRecordStore(), archive, history.FixedRetention(30*24*time.Hour), 2)
+	service.SetSummaries(summaries)
+	result, err := service.Query(context.Background(), "Tokyo", start, end)
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	if len(result.Summaries) != 2 || len(result.Records) != 1 || result.Records[0].ID != missed.ID {
+		t.Errorf("Expected 2 summaries and the missed day's reading from the archive, got %d summaries and %v",
+			len(result.Summaries), recordIDs(result.Records))
+	}
+	if len(result.MissingDays) != 0 {
+		t.Errorf("Expected no missing days with an archive, got %v", result.MissingDays)
+	}
+
+	// Without an archive the gap is reported instead of silently dropped
+	service = history.NewService(storage.NewMemoryRecordStore(), nil, history.FixedRetention(30*24*time.Hour), 2)
+	service.SetSummaries(summaries)
+	if result, err = service.Query(context.Background(), "Tokyo", start, end); err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	if len(result.MissingDays) != 1 || result.MissingDays[0] != day.AddDate(0, 0, 1).Format("2006-01-02") {
+		t.Errorf("Expected the middle day reported missing, got %v", result.MissingDays)
+	}
+	// Days before the first summary are missing too
+	if result, err = service.Query(context.Background(), "Tokyo", day.AddDate(0, 0, -2), end); err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	want := []string{day.AddDate(0, 0, -2).Format("2006-01-02"), day.AddDate(0, 0, -1).Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02")}
+	if !reflect.DeepEqual(result.MissingDays, want) {
+		t.Errorf("Expected missing days %v, got %v", want, result.MissingDays)
+	}
+	// As is every day of a city without summaries
+	if result, err = service.Query(context.Background(), "Osaka", start, end); err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	if len(result.MissingDays) != 3 || len(result.Summaries) != 0 {
+		t.Errorf("Expected all 3 days reported missing, got %v", result.MissingDays)
+	}
+}
+
+func recordIDs(records []models.WeatherRecord) []string {
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+	return ids
+}
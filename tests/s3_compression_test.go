@@ -0,0 +1,34 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/weather-lambda/internal/handlers"
+)
+
+func TestS3Compression(t *testing.T) {
+	payload := []byte(`{"id":"Tokyo-1792128600","cityName":"Tokyo","temperature":21.5}`)
+
+	for _, compression := range []handlers.Compression{handlers.CompressionNone, handlers.CompressionGzip, handlers.CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			encoded, err := compression.Compress(payload)
+			if err != nil {
+				t.Fatalf("Failed to compress: %v", err)
+			}
+
+			decoded, err := handlers.Decompress(bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatalf("Failed to decompress: %v", err)
+			}
+			if !bytes.Equal(decoded, payload) {
+				t.Errorf("Expected %s, got %s", payload, decoded)
+			}
+
+			key := "weather-data/Tokyo-1792128600.json" + compression.KeySuffix()
+			if handlers.CompressionFromKey(key) != compression {
+				t.Errorf("Expected %s to be detected from key %s", compression, key)
+			}
+		})
+	}
+}
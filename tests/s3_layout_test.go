@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+func TestS3KeyLayout(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	createdAt := time.Date(2026, 10, 16, 14, 30, 0, 0, jst)
+	data := &models.S3WeatherData{
+		WeatherRecord: models.WeatherRecord{
+			ID:        "Tokyo-1792128600",
+			Timestamp: createdAt.Format(time.RFC3339),
+			CityName:  "Tokyo",
+			CreatedAt: createdAt,
+		},
+		RawResponse: models.WeatherResponse{
+			Name: "Tokyo",
+			Dt:   time.Date(2026, 10, 16, 5, 20, 0, 0, time.UTC).Unix(),
+		},
+	}
+
+	t.Run("TestHiveLayoutUsesUTCObservationTime", func(t *testing.T) {
+		key := handlers.KeyLayoutHive.ObjectKey("weather-data", data)
+		expected := "weather-data/city=Tokyo/year=2026/month=10/day=16/hour=05/Tokyo-1792128600.json"
+		if key != expected {
+			t.Errorf("Expected key %s, got %s", expected, key)
+		}
+	})
+
+	t.Run("TestLegacyLayout", func(t *testing.T) {
+		key := handlers.KeyLayoutLegacy.ObjectKey("weather-data", data)
+		expected := "weather-data/2026/10-16/Tokyo-1792128600.json"
+		if key != expected {
+			t.Errorf("Expected key %s, got %s", expected, key)
+		}
+	})
+
+	t.Run("TestParseKeyLayout", func(t *testing.T) {
+		if _, err := handlers.ParseKeyLayout("daily"); err == nil {
+			t.Error("Expected an error for an unknown layout")
+		}
+		if layout, err := handlers.ParseKeyLayout("Hive"); err != nil || layout != handlers.KeyLayoutHive {
+			t.Errorf("Expected hive layout, got %q (%v)", layout, err)
+		}
+	})
+}
+
+func TestIteratorReadsBothLayouts(t *testing.T) {
+	store := storage.NewMemoryBlobStore()
+	legacy := handlers.NewS3HandlerFromStore(store, handlers.WithKeyLayout(handlers.KeyLayoutLegacy))
+	hive := handlers.NewS3HandlerFromStore(store, handlers.WithKeyLayout(handlers.KeyLayoutHive))
+
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	unmigrated := newStoredReading("Tokyo", day.Add(2*time.Hour), 20)
+	migrated := newStoredReading("Tokyo", day.Add(4*time.Hour), 21)
+	newer := newStoredReading("Tokyo", day.Add(6*time.Hour), 22)
+	other := newStoredReading("Osaka", day.Add(3*time.Hour), 25)
+	for _, write := range []struct {
+		handler *handlers.S3Handler
+		data    *models.S3WeatherData
+	}{{legacy, unmigrated}, {legacy, migrated}, {hive, migrated}, {hive, newer}, {legacy, other}} {
+		if err := write.handler.StoreWeatherData(write.data); err != nil {
+			t.Fatalf("Failed to store weather data: %v", err)
+		}
+	}
+	unreadable := "weather-data/city=Tokyo/year=2024/month=03/day=15/hour=08/Tokyo-broken.json"
+	if err := hive.StoreObject(unreadable, []byte("not json"), "application/json"); err != nil {
+		t.Fatalf("Failed to store object: %v", err)
+	}
+
+	queries := map[string]handlers.WeatherDataQuery{
+		"Range":     {City: "Tokyo", Start: day, End: day.Add(24*time.Hour - time.Second)},
+		"Unbounded": {City: "Tokyo"},
+	}
+	for name, query := range queries {
+		for _, handler := range []*handlers.S3Handler{legacy, hive} {
+			t.Run(name+"/"+string(handler.KeyLayout()), func(t *testing.T) {
+				it := handler.IterateWeatherData(context.Background(), query)
+				defer it.Close()
+				var ids, keys []string
+				for it.Next() {
+					ids = append(ids, it.Value().ID)
+					keys = append(keys, it.Key())
+				}
+				if err := it.Err(); err != nil {
+					t.Fatalf("Failed to iterate weather data: %v", err)
+				}
+				if len(ids) != 3 || ids[0] != unmigrated.ID || ids[1] != migrated.ID || ids[2] != newer.ID {
+					t.Errorf("Expected each Tokyo record once across both layouts, got %v", ids)
+				}
+				for i := 1; i < len(keys); i++ {
+					if keys[i] <= keys[i-1] {
+						t.Errorf("Expected keys in ascending order, got %v", keys)
+					}
+				}
+				if got := it.Unreadable(); len(got) != 1 || got[0] != unreadable {
+					t.Errorf("Expected the unreadable object to be reported, got %v", got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/awsfake"
+	"github.com/weather-lambda/internal/errs"
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/storage"
+)
+
+func TestS3StoreAndList(t *testing.T) {
+	fake := awsfake.NewS3()
+	s3Handler := handlers.NewS3HandlerFromStore(handlers.NewS3BlobStore(fake, "archive"),
+		handlers.WithKeyLayout(handlers.KeyLayoutHive), handlers.WithCompression(handlers.CompressionGzip))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, city := range []string{"Tokyo", "Osaka", "Tokyo"} {
+		if err := s3Handler.StoreWeatherData(newStoredReading(city, now.Add(-time.Duration(i)*time.Hour), float64(20+i))); err != nil {
+			t.Fatalf("Failed to store weather data: %v", err)
+		}
+	}
+
+	objects, err := s3Handler.ListWeatherData(handlers.DefaultKeyPrefix + "/city=Tokyo/")
+	if err != nil {
+		t.Fatalf("Failed to list weather data: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("Expected 2 Tokyo objects, got %d", len(objects))
+	}
+
+	key := *objects[0].Key
+	data, err := s3Handler.GetWeatherData(key)
+	if err != nil {
+		t.Fatalf("Failed to read %s back: %v", key, err)
+	}
+	if data.CityName != "Tokyo" || s3Handler.ObjectKey(data) != key {
+		t.Errorf("Expected the Tokyo reading stored at %s, got %+v", key, data.WeatherRecord)
+	}
+
+	it := s3Handler.IterateWeatherData(context.Background(), handlers.WeatherDataQuery{Start: now.Add(-3 * time.Hour), End: now})
+	defer it.Close()
+	cities := map[string]int{}
+	for it.Next() {
+		cities[it.Value().CityName]++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Failed to iterate weather data: %v", err)
+	}
+	if cities["Tokyo"] != 2 || cities["Osaka"] != 1 {
+		t.Errorf("Expected every city partition to be read, got %v", cities)
+	}
+
+	if err := s3Handler.CopyWeatherData(key, "backup/"+key); err != nil {
+		t.Fatalf("Failed to copy object: %v", err)
+	}
+	if err := s3Handler.DeleteWeatherData(key); err != nil {
+		t.Fatalf("Failed to delete object: %v", err)
+	}
+	if _, err := s3Handler.GetWeatherData(key); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Expected a deleted object to be not found, got %v", err)
+	}
+	if _, err := s3Handler.GetWeatherData("backup/" + key); err != nil {
+		t.Errorf("Failed to read the copy: %v", err)
+	}
+}
+
+func TestS3ListPagination(t *testing.T) {
+	fake := awsfake.NewS3()
+	store := handlers.NewS3BlobStore(fake, "archive")
+	s3Handler := handlers.NewS3HandlerFromStore(store)
+
+	for i := 0; i < 1005; i++ {
+		if err := s3Handler.StoreObject(fmt.Sprintf("weather-data/2024/01-01/%04d.json", i), []byte("{}"), "application/json"); err != nil {
+			t.Fatalf("Failed to store object: %v", err)
+		}
+	}
+	objects, err := s3Handler.ListWeatherData("weather-data/")
+	if err != nil {
+		t.Fatalf("Failed to list weather data: %v", err)
+	}
+	if len(objects) != 1005 || *objects[1004].Key != "weather-data/2024/01-01/1004.json" {
+		t.Fatalf("Expected all 1005 objects across pages in key order, got %d", len(objects))
+	}
+
+	var pages, prefixes int
+	err = store.List(context.Background(), storage.ListOptions{Prefix: "weather-data/", Delimiter: "/", StartAfter: "weather-data/2023"}, func(page storage.ListPage) bool {
+		pages++
+		prefixes += len(page.CommonPrefixes)
+		return true
+	})
+	if err != nil || pages != 1 || prefixes != 1 {
+		t.Errorf("Expected one page with one common prefix, got %d pages and %d prefixes (%v)", pages, prefixes, err)
+	}
+
+	fake.Fail = func(operation string) error {
+		if strings.HasPrefix(operation, "List") {
+			return awsfake.UnavailableError()
+		}
+		return nil
+	}
+	if _, err := s3Handler.ListWeatherData("weather-data/"); !errors.Is(err, errs.ErrUpstreamUnavailable) {
+		t.Errorf("Expected a failed listing to be upstream unavailable, got %v", err)
+	}
+}
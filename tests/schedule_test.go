@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/schedule"
+)
+
+func TestCityPlanDue(t *testing.T) {
+	plan, err := schedule.ParseCitySchedules([]byte(`[
+		{"city": "Kamakura", "schedule": "@every 10m"},
+		{"city": "Tokyo", "schedule": "@hourly"},
+		{"city": "Sapporo", "schedule": "0 */3 * * *", "activeHours": "06:00-22:00", "timezone": "Asia/Tokyo"},
+		{"city": "Naha", "schedule": "rate(30 minutes)", "activeHours": "22:00-02:00"}
+	]`))
+	if err != nil {
+		t.Fatalf("Failed to parse schedules: %v", err)
+	}
+
+	tick := 10 * time.Minute
+	tests := []struct {
+		at   time.Time
+		want []string
+	}{
+		{time.Date(2024, 3, 15, 10, 10, 0, 0, time.UTC), []string{"Kamakura"}},
+		// 09:00 JST is inside Sapporo's active hours
+		{time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), []string{"Kamakura", "Tokyo", "Sapporo", "Naha"}},
+		// 03:00 JST is outside them, and 15:00 UTC is outside Naha's window
+		{time.Date(2024, 3, 15, 18, 0, 0, 0, time.UTC), []string{"Kamakura", "Tokyo"}},
+		// A late tick still picks up the time it missed
+		{time.Date(2024, 3, 15, 11, 3, 0, 0, time.UTC), []string{"Kamakura", "Tokyo"}},
+	}
+	for _, tt := range tests {
+		if got := plan.Due(tt.at, tick); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Due(%s) = %v, want %v", tt.at.Format(time.RFC3339), got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{
+		`[{"schedule": "@hourly"}]`,
+		`[{"city": "Tokyo", "schedule": "@hourly"}, {"city": "Tokyo", "schedule": "@daily"}]`,
+		`[{"city": "Tokyo", "schedule": "@hourly", "timezone": "Mars/Olympus"}]`,
+		`[{"city": "Tokyo", "schedule": "@hourly", "activeHours": "6-22"}]`,
+	} {
+		if _, err := schedule.ParseCitySchedules([]byte(invalid)); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestScheduleInterval(t *testing.T) {
+	from := time.Date(2024, 3, 15, 10, 7, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Duration
+	}{
+		{"@every 5m", 5 * time.Minute},
+		{"rate(10 minutes)", 10 * time.Minute},
+		{"*/15 * * * *", 15 * time.Minute},
+		{"@hourly", time.Hour},
+		{"@daily", 24 * time.Hour},
+		// Uneven runs can't serve as a tick
+		{"0 9,17 * * *", 0},
+		{"*/7 * * * *", 0},
+		{"0 * * * 1-5", 0},
+	}
+	for _, tt := range tests {
+		sched, err := schedule.Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+		}
+		got, err := schedule.Interval(sched, from)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("Interval(%q) = %s, expected an error", tt.expr, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Interval(%q) = %s, %v, want %s", tt.expr, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/schema"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	t.Run("TestLegacyJSON", func(t *testing.T) {
+		legacy := []byte(`{
+			"id": "Tokyo-1705314600",
+			"timestamp": "2024-01-15T10:30:00Z",
+			"cityName": "Tokyo",
+			"temperature": "8.5",
+			"humidity": "40",
+			"pressure": 1018,
+			"rawResponse": {"name": "Tokyo", "dt": 1705314300}
+		}`)
+
+		var data models.S3WeatherData
+		if err := schema.DecodeJSON(legacy, &data); err != nil {
+			t.Fatalf("Failed to decode legacy payload: %v", err)
+		}
+		if data.SchemaVersion != models.CurrentSchemaVersion {
+			t.Errorf("Expected schema version %d, got %d", models.CurrentSchemaVersion, data.SchemaVersion)
+		}
+		if data.Temperature != 8.5 || data.Humidity != 40 {
+			t.Errorf("Expected numeric strings to be migrated, got temperature %v humidity %v", data.Temperature, data.Humidity)
+		}
+		if want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC); !data.CreatedAt.Equal(want) {
+			t.Errorf("Expected createdAt backfilled from timestamp, got %v", data.CreatedAt)
+		}
+		if data.RawResponse.Dt != 1705314300 {
+			t.Errorf("Expected raw response to be kept, got dt %d", data.RawResponse.Dt)
+		}
+	})
+
+	t.Run("TestLegacyItem", func(t *testing.T) {
+		item := map[string]*dynamodb.AttributeValue{
+			"id":        {S: aws.String("Tokyo-1705314600")},
+			"timestamp": {S: aws.String("2024-01-15T10:30:00Z")},
+			"cityName":  {S: aws.String("Tokyo")},
+			"humidity":  {S: aws.String("40")},
+		}
+
+		var record models.WeatherRecord
+		if err := schema.DecodeItem(item, &record); err != nil {
+			t.Fatalf("Failed to decode legacy item: %v", err)
+		}
+		if record.Humidity != 40 || record.SchemaVersion != models.CurrentSchemaVersion {
+			t.Errorf("Unexpected migrated record: %+v", record)
+		}
+	})
+
+	t.Run("TestUnmigratable", func(t *testing.T) {
+		var migrationErr *schema.MigrationError
+
+		var data models.S3WeatherData
+		err := schema.DecodeJSON([]byte(`{"id": "Tokyo-1", "schemaVersion": 99}`), &data)
+		if !errors.As(err, &migrationErr) {
+			t.Errorf("Expected MigrationError for a future version, got %v", err)
+		}
+
+		err = schema.DecodeJSON([]byte(`{"id": "Tokyo-1", "humidity": "humid"}`), &data)
+		if !errors.As(err, &migrationErr) {
+			t.Errorf("Expected MigrationError for a non-numeric field, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/weather-lambda/internal/schedule"
+	"github.com/weather-lambda/internal/server"
+)
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"@hourly", time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"*/10 * * * *", time.Date(2024, 3, 15, 10, 10, 0, 0, time.UTC)},
+		{"30 1 * * *", time.Date(2024, 3, 16, 1, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * MON-FRI", time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 JAN *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * FRI", time.Date(2024, 3, 22, 0, 0, 0, 0, time.UTC)}, // day of month OR day of week
+		{"@every 15m", time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"rate(1 hour)", time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"cron(30 1 * * ? *)", time.Date(2024, 3, 16, 1, 30, 0, 0, time.UTC)},
+		{"cron(0 12 ? * 1 *)", time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC)}, // EventBridge 1 = Sunday
+	}
+
+	for _, tt := range tests {
+		sched, err := schedule.Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := sched.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * *", "60 * * * *", "0 0 30-2 * *", "@every 10s", "rate(0 minutes)", "cron(0 0 * * ? 2030)"} {
+		if _, err := schedule.Parse(expr); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", expr)
+		}
+	}
+}
+
+func TestAPIGatewayHandler(t *testing.T) {
+	var got events.APIGatewayProxyRequest
+	handler := server.APIGatewayHandler(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		got = request
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusTeapot,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       `{"ok": true}`,
+		}, nil
+	})
+	httpServer := httptest.NewServer(server.RequireAPIKey("secret", handler))
+	defer httpServer.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/weather/history?city=Tokyo&period=24h", nil)
+	request.Header.Set("X-API-Key", "secret")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusTeapot || string(body) != `{"ok": true}` {
+		t.Errorf("Expected the handler's response, got %d %s", response.StatusCode, body)
+	}
+	if got.HTTPMethod != http.MethodGet || got.Path != "/weather/history" {
+		t.Errorf("Unexpected method or path: %s %s", got.HTTPMethod, got.Path)
+	}
+	if got.QueryStringParameters["city"] != "Tokyo" || got.QueryStringParameters["period"] != "24h" {
+		t.Errorf("Unexpected query parameters: %v", got.QueryStringParameters)
+	}
+	if got.Headers["x-api-key"] != "secret" {
+		t.Errorf("Expected the API key header to be passed through, got %v", got.Headers)
+	}
+
+	response, err = http.Get(httpServer.URL + "/weather/history")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 without an API key, got %d", response.StatusCode)
+	}
+}
+
+func TestSchedulerStopsOnCancel(t *testing.T) {
+	scheduler := schedule.NewScheduler()
+	scheduler.Add("tick", schedule.Every(time.Hour), func(ctx context.Context) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scheduler did not stop after cancellation")
+	}
+}
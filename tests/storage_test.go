@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weather-lambda/internal/handlers"
+	"github.com/weather-lambda/internal/history"
+	"github.com/weather-lambda/internal/models"
+	"github.com/weather-lambda/internal/storage"
+)
+
+func newStoredReading(city string, collected time.Time, temperature float64) *models.S3WeatherData {
+	return &models.S3WeatherData{
+		WeatherRecord: models.WeatherRecord{
+			ID:            fmt.Sprintf("%s-%d", city, collected.Unix()),
+			Timestamp:     collected.Format(time.RFC3339),
+			CityName:      city,
+			Temperature:   temperature,
+			CreatedAt:     collected,
+			TTL:           collected.Add(30 * 24 * time.Hour).Unix(),
+			SchemaVersion: models.CurrentSchemaVersion,
+		},
+		RawResponse: models.WeatherResponse{
+			Name: city,
+			Main: models.Main{Temp: temperature},
+			Dt:   collected.Add(-5 * time.Minute).Unix(),
+		},
+	}
+}
+
+func TestRecordStores(t *testing.T) {
+	local, err := storage.NewLocalRecordStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local record store: %v", err)
+	}
+	sqlite, err := storage.NewSQLiteRecordStore(filepath.Join(t.TempDir(), "weather.db"))
+	if err != nil {
+		t.Fatalf("Failed to create SQLite record store: %v", err)
+	}
+	defer sqlite.Close()
+	stores := map[string]storage.RecordStore{
+		"memory": storage.NewMemoryRecordStore(),
+		"local":  local,
+		"sqlite": sqlite,
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				record := newStoredReading("Tokyo", now.Add(time.Duration(i-3)*time.Hour), float64(20+i)).WeatherRecord
+				if err := store.StoreWeatherRecord(&record); err != nil {
+					t.Fatalf("Failed to store record: %v", err)
+				}
+			}
+			expired := newStoredReading("Tokyo", now.Add(-40*24*time.Hour), 5).WeatherRecord
+			if err := store.StoreWeatherRecord(&expired); err != nil {
+				t.Fatalf("Failed to store record: %v", err)
+			}
+			other := newStoredReading("Osaka", now.Add(-time.Hour), 25).WeatherRecord
+			if err := store.StoreWeatherRecord(&other); err != nil {
+				t.Fatalf("Failed to store record: %v", err)
+			}
+
+			records, err := store.GetWeatherHistory(context.Background(), "Tokyo", now.Add(-50*24*time.Hour), now)
+			if err != nil {
+				t.Fatalf("Failed to get history: %v", err)
+			}
+			if len(records) != 3 {
+				t.Fatalf("Expected 3 unexpired Tokyo records, got %d", len(records))
+			}
+			if records[0].Temperature != 20 || records[2].Temperature != 22 {
+				t.Errorf("Expected records oldest first, got %+v", records)
+			}
+
+			recent, err := store.QueryWeatherRecordsByCity("Tokyo", 1)
+			if err != nil {
+				t.Fatalf("Failed to query by city: %v", err)
+			}
+			if len(recent) != 1 || recent[0].Temperature != 22 {
+				t.Errorf("Expected the most recent Tokyo record, got %+v", recent)
+			}
+
+			got, err := store.GetWeatherRecord(other.ID, other.Timestamp)
+			if err != nil || got.CityName != "Osaka" {
+				t.Errorf("Expected to get the Osaka record, got %+v, %v", got, err)
+			}
+			if _, err := store.GetWeatherRecord(expired.ID, expired.Timestamp); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("Expected ErrNotFound for an expired record, got %v", err)
+			}
+
+			// A timestamp with a non-UTC offset sorts by instant, not lexically
+			offset := newStoredReading("Tokyo", now.Add(-150*time.Minute), 30).WeatherRecord
+			offset.Timestamp = offset.CreatedAt.In(time.FixedZone("JST", 9*60*60)).Format(time.RFC3339)
+			if err := store.StoreWeatherRecord(&offset); err != nil {
+				t.Fatalf("Failed to store record: %v", err)
+			}
+			window, err := store.GetWeatherHistory(context.Background(), "Tokyo", now.Add(-165*time.Minute), now.Add(-135*time.Minute))
+			if err != nil || len(window) != 1 || window[0].Temperature != 30 {
+				t.Errorf("Expected only the offset record in its window, got %+v, %v", window, err)
+			}
+			if recent, _ := store.QueryWeatherRecordsByCity("Tokyo", 1); len(recent) != 1 || recent[0].Temperature != 22 {
+				t.Errorf("Expected the offset record not to sort as the most recent, got %+v", recent)
+			}
+
+			// Ranges and recency follow the provider's observation time, as the DynamoDB index does
+			prompt := newStoredReading("Sapporo", now.Add(-60*time.Minute), 8).WeatherRecord
+			prompt.ObservedAt = now.Add(-100 * time.Minute).Unix()
+			late := newStoredReading("Sapporo", now.Add(-30*time.Minute), 6).WeatherRecord
+			late.ObservedAt = now.Add(-110 * time.Minute).Unix()
+			for _, record := range []*models.WeatherRecord{&prompt, &late} {
+				if err := store.StoreWeatherRecord(record); err != nil {
+					t.Fatalf("Failed to store record: %v", err)
+				}
+			}
+			if window, err := store.GetWeatherHistory(context.Background(), "Sapporo", now.Add(-105*time.Minute), now.Add(-80*time.Minute)); err != nil || len(window) != 1 || window[0].Temperature != 8 {
+				t.Errorf("Expected the range to select by observation time, got %+v, %v", window, err)
+			}
+			if window, _ := store.GetWeatherHistory(context.Background(), "Sapporo", now.Add(-70*time.Minute), now); len(window) != 0 {
+				t.Errorf("Expected no records observed after their collection window, got %+v", window)
+			}
+			if recent, _ := store.QueryWeatherRecordsByCity("Sapporo", 1); len(recent) != 1 || recent[0].Temperature != 8 {
+				t.Errorf("Expected the latest observation first, got %+v", recent)
+			}
+		})
+	}
+}
+
+func TestSQLitePurge(t *testing.T) {
+	store, err := storage.NewSQLiteRecordStore(filepath.Join(t.TempDir(), "weather.db"))
+	if err != nil {
+		t.Fatalf("Failed to create SQLite record store: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	kept := newStoredReading("Tokyo", now.Add(-time.Hour), 20).WeatherRecord
+	forever := newStoredReading("Tokyo", now.Add(-90*24*time.Hour), 10).WeatherRecord
+	forever.TTL = 0
+	expired := newStoredReading("Tokyo", now.Add(-40*24*time.Hour), 5).WeatherRecord
+	for _, record := range []*models.WeatherRecord{&kept, &forever, &expired} {
+		if err := store.StoreWeatherRecord(record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+
+	purged, err := store.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged record, got %d", purged)
+	}
+
+	records, err := store.QueryWeatherRecordsByCity("Tokyo", 0)
+	if err != nil {
+		t.Fatalf("Failed to query by city: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != kept.ID || records[1].ID != forever.ID {
+		t.Errorf("Expected the kept and non-expiring records, got %+v", records)
+	}
+}
+
+func TestLocalArchiveHistory(t *testing.T) {
+	blobs, err := storage.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local blob store: %v", err)
+	}
+	archive := handlers.NewS3HandlerFromStore(blobs,
+		handlers.WithKeyLayout(handlers.KeyLayoutHive),
+		handlers.WithCompression(handlers.CompressionGzip),
+	)
+	records := storage.NewMemoryRecordStore()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	old := newStoredReading("Tokyo", now.Add(-45*24*time.Hour), 8)
+	recent := newStoredReading("Tokyo", now.Add(-2*time.Hour), 18)
+	for _, data := range []*models.S3WeatherData{old, recent} {
+		if err := archive.StoreWeatherData(data); err != nil {
+			t.Fatalf("Failed to archive weather data: %v", err)
+		}
+	}
+	// Only the recent readings are still in the record store; one was stamped with a +09:00
+	// offset, so its timestamp sorts after the other's as a string
+	earlier := newStoredReading("Tokyo", now.Add(-3*time.Hour), 17).WeatherRecord
+	earlier.Timestamp = earlier.CreatedAt.In(time.FixedZone("JST", 9*60*60)).Format(time.RFC3339)
+	for _, record := range []*models.WeatherRecord{&recent.WeatherRecord, &earlier} {
+		if err := records.StoreWeatherRecord(record); err != nil {
+			t.Fatalf("Failed to store record: %v", err)
+		}
+	}
+
+	stored, err := archive.GetWeatherData(archive.ObjectKey(old))
+	if err != nil || stored.ID != old.ID {
+		t.Fatalf("Expected to read back the archived reading, got %+v, %v", stored, err)
+	}
+
+	service := history.NewService(records, archive, history.FixedRetention(30*24*time.Hour), 4)
+	result, err := service.Query(context.Background(), "Tokyo", now.Add(-60*24*time.Hour), now)
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	if len(result.Records) != 3 || result.Records[0].ID != old.ID || result.Records[1].ID != earlier.ID || result.Records[2].ID != recent.ID {
+		t.Errorf("Expected archived and recent readings oldest first, got %+v", result.Records)
+	}
+	if len(result.Sources) != 2 {
+		t.Errorf("Expected both sources, got %v", result.Sources)
+	}
+}